@@ -0,0 +1,64 @@
+package liquiddb
+
+//spliceRecord validates the splice against the current data and creates its log record,
+//the caller must hold the writeMutex
+func (db LiquidDb) spliceRecord(path Path, start, deleteCount int, items []interface{}) (logRecord, error) {
+	db.treeMutex.RLock()
+	err := db.tree.checkSplice(path, start, deleteCount)
+	db.treeMutex.RUnlock()
+	if err != nil {
+		return logRecord{}, err
+	}
+
+	return logRecord{
+		Operation: logOperationSplice,
+		Path:      path,
+		Value:     items,
+		Index:     start,
+		Count:     deleteCount,
+	}, nil
+}
+
+//arrayLength returns the amount of elements of the array at path, the caller must hold the writeMutex
+func (db LiquidDb) arrayLength(path Path) int {
+	db.treeMutex.RLock()
+	defer db.treeMutex.RUnlock()
+
+	node := db.tree.findNode(path, false)
+	if node == nil {
+		return 0
+	}
+
+	return node.childCount()
+}
+
+//Splice removes deleteCount elements of the array at path starting from start and inserts items in their place,
+//a missing path is treated as an empty array
+func (db LiquidDb) Splice(path Path, start, deleteCount int, items ...interface{}) ([]EventData, error) {
+	return db.mutate(func() (logRecord, error) {
+		return db.spliceRecord(path, start, deleteCount, items)
+	})
+}
+
+//Push appends values to the end of the array at path
+func (db LiquidDb) Push(path Path, values ...interface{}) ([]EventData, error) {
+	return db.mutate(func() (logRecord, error) {
+		return db.spliceRecord(path, db.arrayLength(path), 0, values)
+	})
+}
+
+//Insert inserts values in the array at path before the element at index
+func (db LiquidDb) Insert(path Path, index int, values ...interface{}) ([]EventData, error) {
+	return db.Splice(path, index, 0, values...)
+}
+
+//RemoveAt removes the element at index from the array at path
+func (db LiquidDb) RemoveAt(path Path, index int) ([]EventData, error) {
+	return db.mutate(func() (logRecord, error) {
+		if index < 0 || index >= db.arrayLength(path) {
+			return logRecord{}, ErrIndexOutOfRange
+		}
+
+		return db.spliceRecord(path, index, 1, nil)
+	})
+}
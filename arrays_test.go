@@ -0,0 +1,219 @@
+package liquiddb
+
+import (
+	"reflect"
+	"testing"
+)
+
+var list = []string{"list"}
+
+func TestArray_SetGet(t *testing.T) {
+	db := New()
+
+	ops, err := db.Set(map[string]interface{}{
+		"list": []interface{}{"a", map[string]interface{}{"b": 1}, []interface{}{true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, op := range ops {
+		if op.Key == "0" && (op.Index == nil || *op.Index != 0) {
+			t.Fatalf("Element event without index %+v", op)
+		}
+	}
+
+	v, _ := db.Get(list)
+	expected := []interface{}{"a", map[string]interface{}{"b": 1}, []interface{}{true}}
+	if !reflect.DeepEqual(v.Value, expected) {
+		t.Fatalf("Invalid array %+v", v.Value)
+	}
+
+	v, _ = db.Get([]string{"list", "1", "b"})
+	if v.Value != 1 {
+		t.Fatalf("Invalid element value %+v", v.Value)
+	}
+
+	db.Set(map[string]interface{}{"list": []interface{}{"c"}})
+	v, _ = db.Get(list)
+	if !reflect.DeepEqual(v.Value, []interface{}{"c"}) {
+		t.Fatalf("Array not replaced %+v", v.Value)
+	}
+
+	db.SetPath(list, []interface{}{})
+	v, _ = db.Get(list)
+	if !reflect.DeepEqual(v.Value, []interface{}{}) {
+		t.Fatalf("Array not emptied %+v", v.Value)
+	}
+}
+
+func TestArray_ListOperations(t *testing.T) {
+	db := New()
+
+	if _, err := db.Push(list, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Insert(list, 1, "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	v, _ := db.Get(list)
+	if !reflect.DeepEqual(v.Value, []interface{}{"a", "c", "b"}) {
+		t.Fatalf("Invalid array after insert %+v", v.Value)
+	}
+
+	ops, err := db.RemoveAt(list, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := map[int]EventData{}
+	for _, op := range ops {
+		if op.Index != nil {
+			events[*op.Index] = op
+		}
+	}
+
+	if events[0].Operation != EventOperationUpdate || events[0].Value != "c" ||
+		events[2].Operation != EventOperationDelete || events[2].Value != "b" {
+		t.Fatalf("Invalid remove events %+v", events)
+	}
+
+	if _, err := db.Splice(list, 1, 1, map[string]interface{}{"d": 1}, "e"); err != nil {
+		t.Fatal(err)
+	}
+
+	v, _ = db.Get(list)
+	if !reflect.DeepEqual(v.Value, []interface{}{"c", map[string]interface{}{"d": 1}, "e"}) {
+		t.Fatalf("Invalid array after splice %+v", v.Value)
+	}
+
+	v, _ = db.Get([]string{"list", "1", "d"})
+	if v.Value != 1 {
+		t.Fatalf("Invalid nested element %+v", v.Value)
+	}
+}
+
+func TestArray_Errors(t *testing.T) {
+	db := New()
+	db.Set(data)
+
+	if _, err := db.Push(p, 1); err != ErrNotArray {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	db.Push(list, 1)
+	if _, err := db.RemoveAt(list, 1); err != ErrIndexOutOfRange {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if _, err := db.Insert(list, 5, 1); err != ErrIndexOutOfRange {
+		t.Fatalf("Invalid error %v", err)
+	}
+}
+
+func TestArray_Replay(t *testing.T) {
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+
+	db := openPersistent(t, path)
+	db.Push(list, 1, 2, 3)
+	db.RemoveAt(list, 1)
+	db.Close()
+
+	db = openPersistent(t, path)
+	defer db.Close()
+
+	v, _ := db.Get(list)
+	if !reflect.DeepEqual(v.Value, []interface{}{1, 3}) {
+		t.Fatalf("Invalid replayed array %+v", v.Value)
+	}
+}
+
+func TestArray_ReplayInvalidPaths(t *testing.T) {
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+
+	db := openPersistent(t, path)
+	db.SetPath(list, []interface{}{1})
+
+	//rejected writes must never reach the log, the database could not be opened again
+	if _, err := db.SetPath([]string{"list", "b"}, 1); err != ErrInvalidIndex {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if _, err := db.Increment([]string{"list", "b"}, 1); err != ErrInvalidIndex {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if _, err := db.Update([]string{"list", "5"}, 1); err != ErrIndexOutOfRange {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if _, err := db.Append([]string{"list", "b"}, []interface{}{1}); err != ErrInvalidIndex {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if _, err := db.Splice([]string{"list", "b"}, 0, 0, 1); err != ErrInvalidIndex {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := NewWithConfig(NewConfigBuilder().LogPath(path).Finalize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	v, _ := db.Get(list)
+	if !reflect.DeepEqual(v.Value, []interface{}{1}) {
+		t.Fatalf("Invalid replayed array %+v", v.Value)
+	}
+}
+
+func TestArray_NoHoles(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	db.SetPath(list, []interface{}{1, 2, 3})
+	if _, ok := db.Delete([]string{"list", "1"}); !ok {
+		t.Fatal("Element not deleted")
+	}
+
+	v, _ := db.Get(list)
+	if !reflect.DeepEqual(v.Value, []interface{}{1, 3}) {
+		t.Fatalf("Array not re-indexed %+v", v.Value)
+	}
+
+	db.Push(list, 4)
+	if _, err := db.Splice(list, 0, 0, 9); err != nil {
+		t.Fatal(err)
+	}
+
+	v, _ = db.Get(list)
+	if !reflect.DeepEqual(v.Value, []interface{}{9, 1, 3, 4}) {
+		t.Fatalf("Invalid array %+v", v.Value)
+	}
+
+	if _, err := db.SetPath([]string{"list", "b"}, 5); err != ErrInvalidIndex {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if _, err := db.SetPath([]string{"list", "5"}, 2); err != ErrIndexOutOfRange {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if _, err := db.SetPath([]string{"list", "4"}, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	db.Delete([]string{"list", "*"})
+	v, _ = db.Get(list)
+	if !reflect.DeepEqual(v.Value, []interface{}{}) {
+		t.Fatalf("Elements not deleted %+v", v.Value)
+	}
+}
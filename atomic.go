@@ -0,0 +1,307 @@
+package liquiddb
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+
+	"github.com/go-errors/errors"
+)
+
+var (
+	//ErrInvalidOperand is returned when the operand of an atomic operation has a type the operation does not support
+	ErrInvalidOperand = errors.New("Invalid operand")
+	//ErrOverflow is returned when the result of an increment does not fit in the type of the value
+	ErrOverflow = errors.New("Numeric overflow")
+
+	atomicOperationNames = map[logOperation]string{
+		logOperationIncrement: "increment",
+		logOperationMin:       "min",
+		logOperationMax:       "max",
+		logOperationAppend:    "append",
+	}
+)
+
+//IncompatibleTypeError is returned when an atomic operation is applied
+//to an existing value of a type the operation does not support
+type IncompatibleTypeError struct {
+	Path      Path
+	Operation string
+	Value     interface{}
+}
+
+func (e *IncompatibleTypeError) Error() string {
+	return fmt.Sprintf("Cannot %s the value at %s, it is of type %T", e.Operation, e.Path, e.Value)
+}
+
+func isInteger(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+
+	return false
+}
+
+//integerValue returns an integer number as a big.Int, a float without a fraction is converted too
+func integerValue(v reflect.Value) (*big.Int, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsInf(f, 0) || math.IsNaN(f) || f != math.Trunc(f) {
+			return nil, false
+		}
+
+		i, _ := big.NewFloat(f).Int(nil)
+		return i, true
+	}
+
+	return nil, false
+}
+
+//addNumbers adds b to a keeping the type of a, unless a is an integer and b has a fraction.
+//It returns ErrOverflow instead of wrapping around when the sum does not fit in the type of a.
+func addNumbers(a, b interface{}) (interface{}, error) {
+	av := reflect.ValueOf(a)
+	aNum, _ := toFloat(a)
+	bNum, _ := toFloat(b)
+
+	if bInt, ok := integerValue(reflect.ValueOf(b)); ok && isInteger(av) {
+		aInt, _ := integerValue(av)
+		sum := aInt.Add(aInt, bInt)
+
+		res := reflect.New(av.Type()).Elem()
+		switch av.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if sum.Sign() < 0 || !sum.IsUint64() || res.OverflowUint(sum.Uint64()) {
+				return nil, ErrOverflow
+			}
+
+			res.SetUint(sum.Uint64())
+		default:
+			if !sum.IsInt64() || res.OverflowInt(sum.Int64()) {
+				return nil, ErrOverflow
+			}
+
+			res.SetInt(sum.Int64())
+		}
+
+		return res.Interface(), nil
+	}
+
+	sum := aNum + bNum
+	if math.IsInf(sum, 0) || (av.Kind() == reflect.Float32 && math.Abs(sum) > math.MaxFloat32) {
+		return nil, ErrOverflow
+	}
+
+	if av.Kind() == reflect.Float32 {
+		return float32(sum), nil
+	}
+
+	return sum, nil
+}
+
+//atomicValue returns the value an atomic operation turns current into, a nil current is a missing value
+func atomicValue(op logOperation, path Path, current, operand interface{}) (interface{}, error) {
+	incompatible := &IncompatibleTypeError{Path: path, Operation: atomicOperationNames[op], Value: current}
+
+	switch op {
+	case logOperationIncrement, logOperationMin, logOperationMax:
+		if valueRank(operand) != 2 {
+			return nil, ErrInvalidOperand
+		}
+
+		if current == nil {
+			return operand, nil
+		}
+
+		if valueRank(current) != 2 {
+			return nil, incompatible
+		}
+
+		c := compareValues(operand, current)
+		switch {
+		case op == logOperationIncrement:
+			return addNumbers(current, operand)
+		case op == logOperationMin && c < 0, op == logOperationMax && c > 0:
+			return operand, nil
+		default:
+			return current, nil
+		}
+	case logOperationAppend:
+		suffix, ok := operand.(string)
+		if !ok {
+			return nil, ErrInvalidOperand
+		}
+
+		if current == nil {
+			return suffix, nil
+		}
+
+		s, ok := current.(string)
+		if !ok {
+			return nil, incompatible
+		}
+
+		return s + suffix, nil
+	default:
+		return nil, errors.Errorf("Invalid atomic operation %d", op)
+	}
+}
+
+//appendItems returns the items appending operand to an array adds, the items of an array operand are added one by one
+func appendItems(operand interface{}) []interface{} {
+	if items, ok := operand.([]interface{}); ok {
+		return items
+	}
+
+	return []interface{}{operand}
+}
+
+//checkAtomic returns the error applying an atomic operation to the current data would have
+func (t *tree) checkAtomic(op logOperation, path Path, operand interface{}) error {
+	if err := t.checkArrayPath(path); err != nil {
+		return err
+	}
+
+	node := t.findNode(path, false)
+	if t.isArrayAppend(op, node, operand) {
+		return nil
+	}
+
+	if node != nil && node.childCount() > 0 {
+		return &IncompatibleTypeError{Path: path, Operation: atomicOperationNames[op], Value: t.jsonValue(node)}
+	}
+
+	var current interface{}
+	if node != nil {
+		current = node.GetValue()
+	}
+
+	_, err := atomicValue(op, path, current, operand)
+	return err
+}
+
+//isArrayAppend returns whether op appends to an array, a missing node becomes an array when the operand is one
+func (t *tree) isArrayAppend(op logOperation, node *Node, operand interface{}) bool {
+	if op != logOperationAppend {
+		return false
+	}
+
+	if node == nil {
+		_, ok := operand.([]interface{})
+		return ok
+	}
+
+	return node.IsArray()
+}
+
+//atomic applies an atomic operation to the value at path and returns its single event
+func (t *tree) atomic(op logOperation, path Path, operand interface{}) ([]EventData, error) {
+	if err := t.checkAtomic(op, path, operand); err != nil {
+		return nil, err
+	}
+
+	node := t.findNode(path, false)
+	eventOp := EventOperationUpdate
+	if node == nil {
+		eventOp = EventOperationInsert
+	}
+
+	if t.isArrayAppend(op, node, operand) {
+		length := 0
+		var oldValue interface{}
+		if node != nil {
+			length = node.childCount()
+			oldValue = t.jsonValue(node)
+		}
+
+		if _, err := t.Splice(path, length, 0, appendItems(operand)); err != nil {
+			return nil, err
+		}
+
+		node = t.findNode(path, false)
+		return []EventData{{
+			Key:       node.Key,
+			Operation: eventOp,
+			Path:      node.Path,
+			Value:     t.jsonValue(node),
+			OldValue:  oldValue,
+			Version:   node.GetVersion(),
+			Index:     t.index(node),
+		}}, nil
+	}
+
+	var oldValue interface{}
+	if node != nil {
+		oldValue = node.GetValue()
+	}
+
+	value, err := atomicValue(op, path, oldValue, operand)
+	if err != nil {
+		return nil, err
+	}
+
+	node = t.findNode(path, true)
+	revision := t.nextRevision()
+	t.setValue(node, value)
+	node.array = false
+	node.pristine = false
+	t.setAncestorsVersion(node.Path, revision)
+
+	return []EventData{{
+		Key:       node.Key,
+		Operation: eventOp,
+		Path:      node.Path,
+		Value:     value,
+		OldValue:  oldValue,
+		Version:   revision,
+		Index:     t.index(node),
+	}}, nil
+}
+
+//atomic commits an atomic operation after checking it against the current data,
+//so an incompatible operation never reaches the log
+func (db LiquidDb) atomic(op logOperation, path Path, operand interface{}) ([]EventData, error) {
+	return db.mutate(func() (logRecord, error) {
+		db.treeMutex.RLock()
+		err := db.tree.checkAtomic(op, path, operand)
+		db.treeMutex.RUnlock()
+		if err != nil {
+			return logRecord{}, err
+		}
+
+		return logRecord{
+			Operation: op,
+			Path:      path,
+			Value:     operand,
+		}, nil
+	})
+}
+
+//Increment atomically adds delta to the number at path, a missing value is treated as 0
+func (db LiquidDb) Increment(path Path, delta interface{}) ([]EventData, error) {
+	return db.atomic(logOperationIncrement, path, delta)
+}
+
+//Min atomically sets the number at path to value if value is smaller, a missing value is set to value
+func (db LiquidDb) Min(path Path, value interface{}) ([]EventData, error) {
+	return db.atomic(logOperationMin, path, value)
+}
+
+//Max atomically sets the number at path to value if value is bigger, a missing value is set to value
+func (db LiquidDb) Max(path Path, value interface{}) ([]EventData, error) {
+	return db.atomic(logOperationMax, path, value)
+}
+
+//Append atomically appends a string to the string at path or a value to the array at path,
+//the items of an array value are appended one by one
+func (db LiquidDb) Append(path Path, value interface{}) ([]EventData, error) {
+	return db.atomic(logOperationAppend, path, value)
+}
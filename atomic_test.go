@@ -0,0 +1,157 @@
+package liquiddb
+
+import (
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+var likes = Path{"posts", "p1", "likes"}
+
+func TestAtomic_Increment(t *testing.T) {
+	db := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db.Increment(likes, 1)
+		}()
+	}
+	wg.Wait()
+
+	v, _ := db.Get(likes)
+	if v.Value != 50 {
+		t.Fatalf("Invalid count %+v", v.Value)
+	}
+
+	ops, err := db.Increment(likes, float64(-10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ops) != 1 || ops[0].Operation != EventOperationUpdate || ops[0].Value != 40 {
+		t.Fatalf("Invalid increment events %+v", ops)
+	}
+
+	db.Increment(likes, 0.5)
+	if v, _ := db.Get(likes); v.Value != 40.5 {
+		t.Fatalf("Invalid fractional increment %+v", v.Value)
+	}
+}
+
+func TestAtomic_MinMax(t *testing.T) {
+	db := New()
+	price := Path{"items", "i1", "lowest"}
+
+	for _, p := range []int{5, 3, 7} {
+		db.Min(price, p)
+	}
+
+	if v, _ := db.Get(price); v.Value != 3 {
+		t.Fatalf("Invalid min %+v", v.Value)
+	}
+
+	ops, _ := db.Max(price, 10)
+	if len(ops) != 1 || ops[0].Value != 10 {
+		t.Fatalf("Invalid max events %+v", ops)
+	}
+}
+
+func TestAtomic_Append(t *testing.T) {
+	db := New()
+	log := Path{"log"}
+
+	db.Append(log, "a")
+	db.Append(log, "b")
+	if v, _ := db.Get(log); v.Value != "ab" {
+		t.Fatalf("Invalid string append %+v", v.Value)
+	}
+
+	ops, err := db.Append(list, []interface{}{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Append(list, 3)
+	if len(ops) != 1 || ops[0].Operation != EventOperationInsert {
+		t.Fatalf("Invalid array append events %+v", ops)
+	}
+
+	if v, _ := db.Get(list); !reflect.DeepEqual(v.Value, []interface{}{1, 2, 3}) {
+		t.Fatalf("Invalid array append %+v", v.Value)
+	}
+}
+
+func TestAtomic_Errors(t *testing.T) {
+	db := New()
+	db.Set(data)
+
+	_, err := db.Increment(p, 1)
+	typeErr, ok := err.(*IncompatibleTypeError)
+	if !ok || typeErr.Operation != "increment" || !reflect.DeepEqual(typeErr.Path, p) {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if _, err := db.Append([]string{"foo"}, "x"); err == nil {
+		t.Fatal("Appended to an object")
+	}
+
+	if _, err := db.Increment(likes, "1"); err != ErrInvalidOperand {
+		t.Fatalf("Invalid error %v", err)
+	}
+}
+
+func TestAtomic_Replay(t *testing.T) {
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+
+	db := openPersistent(t, path)
+	db.Increment(likes, 2)
+	db.Increment(likes, 3)
+	db.Increment(p, 1)
+	db.Close()
+
+	db = openPersistent(t, path)
+	defer db.Close()
+
+	if v, _ := db.Get(likes); v.Value != 5 {
+		t.Fatalf("Invalid replayed count %+v", v.Value)
+	}
+}
+
+func TestAtomic_Overflow(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	counter := Path{"counter"}
+	db.SetPath(counter, uint8(250))
+	if _, err := db.Increment(counter, 10); err != ErrOverflow {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if _, err := db.Increment(counter, -251); err != ErrOverflow {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if v, _ := db.Get(counter); v.Value != uint8(250) {
+		t.Fatalf("Value changed by a failed increment %+v", v.Value)
+	}
+
+	db.Increment(counter, 5)
+	if v, _ := db.Get(counter); v.Value != uint8(255) {
+		t.Fatalf("Invalid count %+v", v.Value)
+	}
+
+	db.SetPath(counter, int64(math.MaxInt64))
+	if _, err := db.Increment(counter, uint64(math.MaxUint64)); err != ErrOverflow {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	db.SetPath(counter, math.MaxFloat64)
+	if _, err := db.Increment(counter, math.MaxFloat64); err != ErrOverflow {
+		t.Fatalf("Invalid error %v", err)
+	}
+}
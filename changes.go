@@ -0,0 +1,96 @@
+package liquiddb
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+//ErrChangesUnavailable is returned when the changes after a sequence number are no longer retained
+//or the sequence number was not committed yet
+var ErrChangesUnavailable = errors.New("Changes unavailable")
+
+//changeEntry holds the events of a committed mutation
+type changeEntry struct {
+	sequence uint64
+	events   []EventData
+}
+
+//changeLog numbers the committed mutations and retains the events of the most recent ones
+//so the subscribers can resume after the last one they have seen
+type changeLog struct {
+	mutex     sync.Mutex
+	sequence  uint64
+	retention int
+	entries   []changeEntry
+}
+
+func newChangeLog(retention int) *changeLog {
+	return &changeLog{retention: retention}
+}
+
+//record gives the next sequence number to the events of a mutation, they are timestamped and retained with the link id
+func (l *changeLog) record(events []EventData, id uint64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.sequence++
+	now := time.Now().UTC()
+	retained := make([]EventData, len(events))
+	for i := range events {
+		events[i].Sequence = l.sequence
+		events[i].Timestamp = now
+		retained[i] = events[i]
+		retained[i].ID = id
+	}
+
+	if l.retention <= 0 {
+		return
+	}
+
+	l.entries = append(l.entries, changeEntry{sequence: l.sequence, events: retained})
+	if len(l.entries) > l.retention {
+		//copy the kept entries so the dropped ones can be collected
+		l.entries = append([]changeEntry(nil), l.entries[len(l.entries)-l.retention:]...)
+	}
+}
+
+//current returns the sequence number of the last committed mutation
+func (l *changeLog) current() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.sequence
+}
+
+//since returns the events of the mutations after from and the sequence number of the last one,
+//the caller must hold the lock of the log
+func (l *changeLog) since(from uint64) ([]EventData, error) {
+	if from > l.sequence {
+		return nil, ErrChangesUnavailable
+	}
+
+	oldest := l.sequence + 1
+	if len(l.entries) > 0 {
+		oldest = l.entries[0].sequence
+	}
+
+	if from+1 < oldest {
+		return nil, ErrChangesUnavailable
+	}
+
+	events := make([]EventData, 0)
+	for _, entry := range l.entries {
+		if entry.sequence > from {
+			events = append(events, entry.events...)
+		}
+	}
+
+	return events, nil
+}
+
+//Sequence returns the sequence number of the last committed mutation
+func (db LiquidDb) Sequence() uint64 {
+	return db.changes.current()
+}
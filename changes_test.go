@@ -0,0 +1,212 @@
+package liquiddb
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChanges_Sequence(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	ev, _ := db.SetPath(Path{"a"}, 1)
+	if ev[0].Sequence != 1 || db.Sequence() != 1 {
+		t.Fatalf("Invalid sequence %d", ev[0].Sequence)
+	}
+
+	ev, _ = db.Transaction(func(tx *Tx) error {
+		tx.SetPath(Path{"b"}, 1)
+		tx.SetPath(Path{"c"}, 1)
+		return nil
+	})
+
+	if len(ev) != 2 || ev[0].Sequence != 2 || ev[1].Sequence != 2 {
+		t.Fatalf("Invalid transaction sequence %+v", ev)
+	}
+
+	if _, err := db.SetPathIf(Path{"a"}, 2, 100); err == nil || db.Sequence() != 2 {
+		t.Fatal("Failed write got a sequence number")
+	}
+}
+
+//receiveSequences receives the events of the mutations up to last and fails on gaps and duplicates
+func receiveSequences(t *testing.T, ch chan EventData, from, last uint64) {
+	t.Helper()
+
+	expected := from + 1
+	timeout := time.After(5 * time.Second)
+	for expected <= last {
+		select {
+		case ev := <-ch:
+			if ev.Sequence != expected {
+				t.Fatalf("Received %d, expected %d", ev.Sequence, expected)
+			}
+
+			expected++
+		case <-timeout:
+			t.Fatalf("Missing %d", expected)
+		}
+	}
+
+	select {
+	case ev := <-ch:
+		t.Fatalf("Unexpected event %d", ev.Sequence)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestChanges_Resume(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	for i := 0; i < 10; i++ {
+		db.SetPath(Path{strconv.Itoa(i)}, i)
+	}
+
+	ch := make(chan EventData, 100)
+	if err := db.Changes(ch, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetPath(Path{"10"}, 10)
+	db.Get(Path{"10"})
+	receiveSequences(t, ch, 5, 11)
+}
+
+func TestChanges_ConcurrentWrites(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				db.SetPath(Path{strconv.Itoa(w*50 + i)}, i)
+			}
+		}(w)
+	}
+
+	//subscribe while the writes are committed and published
+	time.Sleep(time.Millisecond)
+	from := db.Sequence() / 2
+	ch := make(chan EventData, 1000)
+	if err := db.Changes(ch, from); err != nil {
+		t.Fatal(err)
+	}
+
+	wg.Wait()
+
+	//the live events of concurrent writers arrive in the order of their sequence numbers too
+	receiveSequences(t, ch, from, 200)
+}
+
+func TestChanges_ConcurrentOrder(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	ch := make(chan EventData, 3000)
+	if err := db.Changes(ch, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 300; i++ {
+				db.SetPath(Path{strconv.Itoa(w), strconv.Itoa(i)}, i)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	var last uint64
+	timeout := time.After(10 * time.Second)
+	for last < 2400 {
+		select {
+		case ev := <-ch:
+			if ev.Sequence < last {
+				t.Fatalf("Event %d arrived after %d", ev.Sequence, last)
+			}
+
+			last = ev.Sequence
+		case <-timeout:
+			t.Fatalf("Received the events until %d of 2400", last)
+		}
+	}
+}
+
+func TestChanges_NotifyPathFrom(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	db.SetPath(Path{"users", "a"}, 1)
+	ch := make(chan EventData, 100)
+	db.NotifyPath(ch, Path{"users"}, EventOperationInsert)
+	db.SetPath(Path{"users", "b"}, 1)
+	db.SetPath(Path{"orders", "a"}, 1)
+
+	//the events under users were already sent by the first subscription
+	if err := db.NotifyPathFrom(ch, Path{}, 0, SubscriberOptions{}, EventOperationInsert); err != nil {
+		t.Fatal(err)
+	}
+
+	//the live event can be sent before or after the replayed ones
+	paths := receivePaths(ch)
+	sort.Strings(paths)
+	if !reflect.DeepEqual(paths, []string{"orders.a", "users.a", "users.b"}) {
+		t.Fatalf("Invalid events %v", paths)
+	}
+}
+
+func TestChanges_Unavailable(t *testing.T) {
+	db, _ := NewWithConfig(NewConfigBuilder().ChangeRetention(2).Finalize())
+	defer db.Close()
+
+	for i := 0; i < 5; i++ {
+		db.SetPath(Path{strconv.Itoa(i)}, i)
+	}
+
+	if err := db.Changes(make(chan EventData), 2); err != ErrChangesUnavailable {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if err := db.Changes(make(chan EventData), 6); err != ErrChangesUnavailable {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	ch := make(chan EventData, 10)
+	if err := db.Changes(ch, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	receiveSequences(t, ch, 3, 5)
+}
+
+func TestChanges_Persistence(t *testing.T) {
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+
+	db := openPersistent(t, path)
+	db.SetPath(Path{"a"}, 1)
+	db.SetPath(Path{"b"}, 1)
+	db.Close()
+
+	db = openPersistent(t, path)
+	defer db.Close()
+
+	ch := make(chan EventData, 10)
+	if err := db.Changes(ch, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetPath(Path{"c"}, 1)
+	receiveSequences(t, ch, 1, 3)
+}
@@ -0,0 +1,123 @@
+package liquiddb
+
+//mergeChange merges a later event of a mutation at the same path into the first one, the result
+//goes from the state before the mutation to the one after it. It returns false when the path
+//neither existed before nor exists after the mutation.
+func mergeChange(first, later EventData) (EventData, bool) {
+	merged := later
+	merged.OldValue = first.OldValue
+
+	switch {
+	case first.Operation == EventOperationInsert && later.Operation == EventOperationDelete:
+		return EventData{}, false
+	case first.Operation == EventOperationInsert:
+		merged.Operation = EventOperationInsert
+		merged.OldValue = nil
+	case first.Operation == EventOperationDelete && later.Operation != EventOperationDelete:
+		merged.Operation = EventOperationUpdate
+	}
+
+	return merged, true
+}
+
+//newChangeSet creates the ChangeSet event of the events of a mutation, it holds one event per changed path
+//in the order they first changed and the paths of the changed leaves
+func newChangeSet(events []EventData) EventData {
+	changes := make([]EventData, 0, len(events))
+	indexes := make(map[string]int)
+	for _, ev := range events {
+		key := ev.Path.Relative().String()
+		i, ok := indexes[key]
+		if !ok {
+			indexes[key] = len(changes)
+			changes = append(changes, ev)
+			continue
+		}
+
+		//an empty operation marks a path which was inserted and deleted again
+		if changes[i].Operation == "" {
+			changes[i] = ev
+		} else if merged, ok := mergeChange(changes[i], ev); ok {
+			changes[i] = merged
+		} else {
+			changes[i].Operation = ""
+		}
+	}
+
+	kept := changes[:0]
+	for _, change := range changes {
+		if change.Operation != "" {
+			kept = append(kept, change)
+		}
+	}
+
+	changeSet := EventData{
+		ID:        events[0].ID,
+		Operation: EventOperationChangeSet,
+		Changes:   kept,
+		Leaves:    changedLeaves(kept),
+		Sequence:  events[0].Sequence,
+		Timestamp: events[0].Timestamp,
+	}
+
+	for i, leaf := range changeSet.Leaves {
+		if i == 0 {
+			changeSet.Path = append(Path{}, leaf...)
+			continue
+		}
+
+		n := 0
+		for n < len(changeSet.Path) && n < len(leaf) && changeSet.Path[n] == leaf[n] {
+			n++
+		}
+
+		changeSet.Path = changeSet.Path[:n]
+	}
+
+	if len(changeSet.Path) > 0 {
+		changeSet.Key = changeSet.Path[len(changeSet.Path)-1]
+	}
+
+	return changeSet
+}
+
+//changedLeaves returns the paths of the changes which are not ancestors of other changes
+func changedLeaves(changes []EventData) []Path {
+	ancestors := make(map[string]bool)
+	for _, change := range changes {
+		path := change.Path.Relative()
+		for i := range path {
+			ancestors[path[:i].String()] = true
+		}
+	}
+
+	leaves := make([]Path, 0)
+	for _, change := range changes {
+		if !ancestors[change.Path.Relative().String()] {
+			leaves = append(leaves, change.Path.Relative())
+		}
+	}
+
+	return leaves
+}
+
+//changeSets groups the events of each mutation into a ChangeSet, the other events are kept as they are
+func changeSets(events []EventData) []EventData {
+	res := make([]EventData, 0)
+	for i := 0; i < len(events); {
+		j := i + 1
+		for j < len(events) && events[j].Sequence == events[i].Sequence {
+			j++
+		}
+
+		if events[i].Sequence == 0 {
+			res = append(res, events[i:j]...)
+		} else {
+			res = append(res, newChangeSet(events[i:j]))
+		}
+
+		i = j
+	}
+
+	return res
+}
@@ -0,0 +1,121 @@
+package liquiddb
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func receiveEvent(t *testing.T, ch chan EventData) EventData {
+	t.Helper()
+
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("No event")
+	}
+
+	return EventData{}
+}
+
+func changePaths(changes []EventData) []string {
+	paths := make([]string, len(changes))
+	for i, change := range changes {
+		paths[i] = string(change.Operation) + " " + change.Path.Relative().String()
+	}
+
+	return paths
+}
+
+func TestChangeSet_Mutation(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	ch := make(chan EventData, 10)
+	db.NotifyPathWithOptions(ch, Path{}, SubscriberOptions{ChangeSets: true},
+		EventOperationInsert, EventOperationUpdate, EventOperationDelete, EventOperationGet)
+
+	db.Set(map[string]interface{}{"a": map[string]interface{}{"b": 1, "c": 2}, "d": 1})
+	set := receiveEvent(t, ch)
+	if set.Operation != EventOperationChangeSet || set.Sequence != 1 {
+		t.Fatalf("Invalid change set %+v", set)
+	}
+
+	//the keys of a document are set in no particular order
+	paths := changePaths(set.Changes)
+	sort.Strings(paths)
+	if !reflect.DeepEqual(paths, []string{"insert a", "insert a.b", "insert a.c", "insert d"}) {
+		t.Fatalf("Invalid changes %v", paths)
+	}
+
+	leaves := make([]string, 0)
+	for _, leaf := range set.Leaves {
+		leaves = append(leaves, leaf.String())
+	}
+
+	sort.Strings(leaves)
+	if !reflect.DeepEqual(leaves, []string{"a.b", "a.c", "d"}) || len(set.Path) != 0 {
+		t.Fatalf("Invalid leaves %v %v", leaves, set.Path)
+	}
+
+	db.SetPath(Path{"a"}, map[string]interface{}{"b": 5, "c": 6})
+	set = receiveEvent(t, ch)
+	paths = changePaths(set.Changes)
+	sort.Strings(paths)
+	if !reflect.DeepEqual(paths, []string{"update a", "update a.b", "update a.c"}) {
+		t.Fatalf("Invalid changes %v", paths)
+	}
+
+	if !reflect.DeepEqual(set.Path, Path{"a"}) || len(set.Leaves) != 2 {
+		t.Fatalf("Invalid change set %+v", set)
+	}
+
+	db.Get(Path{"d"})
+	if get := receiveEvent(t, ch); get.Operation != EventOperationGet {
+		t.Fatalf("Get was batched %+v", get)
+	}
+}
+
+func TestChangeSet_Subscriptions(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	db.SetPath(Path{"a", "x"}, 1)
+
+	ch := make(chan EventData, 10)
+	db.NotifyPathFrom(ch, Path{"a"}, 0, SubscriberOptions{ChangeSets: true}, EventOperationInsert)
+	db.Transaction(func(tx *Tx) error {
+		tx.SetPath(Path{"a", "y"}, 1)
+		tx.SetPath(Path{"b"}, 1)
+		return nil
+	})
+
+	//the replayed mutation and the live one
+	for _, expected := range []string{"insert a.x", "insert a.y"} {
+		set := receiveEvent(t, ch)
+		if paths := changePaths(set.Changes); len(paths) != 1 || paths[0] != expected {
+			t.Fatalf("Invalid changes %v", paths)
+		}
+	}
+}
+
+func TestChangeSet_Merge(t *testing.T) {
+	set := newChangeSet([]EventData{
+		{Operation: EventOperationInsert, Path: Path{"a"}, Value: 1, Sequence: 1},
+		{Operation: EventOperationUpdate, Path: Path{"a"}, Value: 2, OldValue: 1},
+		{Operation: EventOperationDelete, Path: Path{"b"}, OldValue: 1},
+		{Operation: EventOperationInsert, Path: Path{"b"}, Value: 2},
+		{Operation: EventOperationInsert, Path: Path{"c"}, Value: 1},
+		{Operation: EventOperationDelete, Path: Path{"c"}, OldValue: 1},
+	})
+
+	if paths := changePaths(set.Changes); !reflect.DeepEqual(paths, []string{"insert a", "update b"}) {
+		t.Fatalf("Invalid changes %v", paths)
+	}
+
+	if set.Changes[0].Value != 2 || set.Changes[0].OldValue != nil || set.Changes[1].OldValue != 1 {
+		t.Fatalf("Invalid merged values %+v", set.Changes)
+	}
+}
@@ -10,9 +10,10 @@ import (
 )
 
 type ClientConnection interface {
-	WriteInterested(path string, o liquiddb.EventData) (bool, error)
-	AddInterest(interest string, op liquiddb.EventOperation, o operations.OperationClientData) error
-	RemoveInterest(interest string, op liquiddb.EventOperation, o operations.OperationClientData)
+	WriteInterested(o liquiddb.EventData) (liquiddb.EventData, bool, error)
+	AddInterest(interest liquiddb.Path, op liquiddb.EventOperation, o operations.OperationClientData) error
+	RemoveInterest(interest liquiddb.Path, op liquiddb.EventOperation, o operations.OperationClientData) int
+	Events() chan liquiddb.EventData
 
 	GetLatencyHistory() [3]int32
 	SetLatencyHistory([3]int32)
@@ -30,7 +31,10 @@ type ClientConnection interface {
 
 type clientConnection struct {
 	interestsMutex deadlock.Mutex
-	interests      map[string][]*operations.ClientInterest
+	//interests are keyed by the string form of their paths
+	interests map[string][]*operations.ClientInterest
+	//patterns holds the interests that are path patterns
+	patterns map[string]liquiddb.Path
 
 	latencyHistoryMutex deadlock.Mutex
 	latencyHistory      [3]int32
@@ -39,12 +43,16 @@ type clientConnection struct {
 	latency      int32
 
 	hearthbeatResponse chan struct{}
+
+	//events receives the db events under the paths of the interests
+	events chan liquiddb.EventData
 }
 
 func newClientConnection() *clientConnection {
 	c := &clientConnection{
 		interestsMutex: deadlock.Mutex{},
 		interests:      map[string][]*operations.ClientInterest{},
+		patterns:       map[string]liquiddb.Path{},
 
 		latencyHistoryMutex: deadlock.Mutex{},
 		latencyHistory:      [3]int32{},
@@ -52,6 +60,7 @@ func newClientConnection() *clientConnection {
 		latencyMutex:       deadlock.Mutex{},
 		latency:            0,
 		hearthbeatResponse: make(chan struct{}),
+		events:             make(chan liquiddb.EventData, 10),
 	}
 
 	return c
@@ -61,6 +70,10 @@ func (c *clientConnection) HearthbeatResponse() chan struct{} {
 	return c.hearthbeatResponse
 }
 
+func (c *clientConnection) Events() chan liquiddb.EventData {
+	return c.events
+}
+
 func (c *clientConnection) GetLatencyHistory() [3]int32 {
 	c.latencyHistoryMutex.Lock()
 	defer c.latencyHistoryMutex.Unlock()
@@ -87,21 +100,35 @@ func (c *clientConnection) SetLatency(l int32) {
 	c.latencyMutex.Unlock()
 }
 
-func (c *clientConnection) WriteInterested(path string, o liquiddb.EventData) (bool, error) {
+//WriteInterested returns whether the connection is interested in the event and the event to write,
+//events matched by a pattern interest carry the pattern and its captured params
+func (c *clientConnection) WriteInterested(o liquiddb.EventData) (liquiddb.EventData, bool, error) {
 	c.interestsMutex.Lock()
 	defer c.interestsMutex.Unlock()
 
-	op := o.Operation
-
 	//TODO: operations including root should be optimized and cleaned up
-	interests := c.interests[path]
+	interests := c.interests[interestKey(o.Path)]
 	if interests == nil {
 		interests = c.interests[liquiddb.TreeRoot]
-		if interests == nil {
-			return false, nil
+	}
+
+	if isInterested(interests, o) {
+		return o, true, nil
+	}
+
+	for interest, pattern := range c.patterns {
+		params, ok := liquiddb.MatchPath(pattern, o.Path)
+		if ok && isInterested(c.interests[interest], o) {
+			o.Pattern = pattern
+			o.Params = params
+			return o, true, nil
 		}
 	}
 
+	return o, false, nil
+}
+
+func isInterested(interests []*operations.ClientInterest, o liquiddb.EventData) bool {
 	for _, interest := range interests {
 		log.WithFields(log.Fields{
 			"id":        interest.Id,
@@ -110,23 +137,34 @@ func (c *clientConnection) WriteInterested(path string, o liquiddb.EventData) (b
 		}).Debug("Interest")
 
 		interestHasValidTimestamp := o.Timestamp.After(interest.Timestamp) || o.Timestamp.Equal(interest.Timestamp)
-		if interest.Operation == op && interestHasValidTimestamp {
-			return true, nil
+		if interest.Sequence != nil && o.Sequence != 0 {
+			interestHasValidTimestamp = o.Sequence > *interest.Sequence
+		}
+
+		if interest.Operation == o.Operation && interestHasValidTimestamp {
+			return true
 		}
 	}
 
-	return false, nil
+	return false
+}
+
+//interestKey returns the canonical string form of path, the root is TreeRoot
+func interestKey(path liquiddb.Path) string {
+	key := path.Relative().String()
+	if key == "" {
+		return liquiddb.TreeRoot
+	}
+
+	return key
 }
 
-func (c *clientConnection) AddInterest(interest string, op liquiddb.EventOperation, o operations.OperationClientData) error {
+func (c *clientConnection) AddInterest(path liquiddb.Path, op liquiddb.EventOperation, o operations.OperationClientData) error {
 	c.interestsMutex.Lock()
 	defer c.interestsMutex.Unlock()
 
 	timestamp := o.Timestamp
-
-	if interest == "" {
-		interest = liquiddb.TreeRoot
-	}
+	interest := interestKey(path)
 
 	interests := c.interests[interest]
 	t, err := time.Parse(time.RFC3339, timestamp)
@@ -142,9 +180,10 @@ func (c *clientConnection) AddInterest(interest string, op liquiddb.EventOperati
 	c.latencyMutex.Unlock()
 
 	cInterest := &operations.ClientInterest{
-		o.ID,
-		op,
-		t,
+		Id:        o.ID,
+		Operation: op,
+		Timestamp: t,
+		Sequence:  o.Sequence,
 	}
 	if interests == nil {
 		c.interests[interest] = []*operations.ClientInterest{cInterest}
@@ -152,16 +191,19 @@ func (c *clientConnection) AddInterest(interest string, op liquiddb.EventOperati
 		c.interests[interest] = append(interests, cInterest)
 	}
 
+	if liquiddb.IsPattern(path) {
+		c.patterns[interest] = path.Relative()
+	}
+
 	return nil
 }
 
-func (c *clientConnection) RemoveInterest(interest string, op liquiddb.EventOperation, o operations.OperationClientData) {
+//RemoveInterest removes the interests of the client operation in op at path and returns how many were removed
+func (c *clientConnection) RemoveInterest(path liquiddb.Path, op liquiddb.EventOperation, o operations.OperationClientData) int {
 	c.interestsMutex.Lock()
 	defer c.interestsMutex.Unlock()
 
-	if interest == "" {
-		interest = liquiddb.TreeRoot
-	}
+	interest := interestKey(path)
 
 	interests := c.interests[interest]
 	if interests == nil || len(interests) == 0 {
@@ -169,16 +211,21 @@ func (c *clientConnection) RemoveInterest(interest string, op liquiddb.EventOper
 			"interest":  interest,
 			"operation": op,
 		}).Warn("Trying to remove unexisting interest")
-		return
+		return 0
 	}
 
-	for i, cInterest := range interests {
-		if cInterest.Operation == op && cInterest.Id == o.ID {
-			c.interests[interest] = append(interests[:i], interests[i+1:]...)
+	kept := make([]*operations.ClientInterest, 0, len(interests))
+	for _, cInterest := range interests {
+		if cInterest.Operation != op || cInterest.Id != o.ID {
+			kept = append(kept, cInterest)
 		}
 	}
 
-	if len(c.interests[interest]) == 0 {
+	c.interests[interest] = kept
+	if len(kept) == 0 {
 		delete(c.interests, interest)
+		delete(c.patterns, interest)
 	}
+
+	return len(interests) - len(kept)
 }
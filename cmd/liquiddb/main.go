@@ -1,7 +1,9 @@
 package main
 
 import (
+	"flag"
 	"os"
+	"strings"
 	"sync"
 
 	log "github.com/sirupsen/logrus"
@@ -12,12 +14,47 @@ import (
 
 //tests for this package will be written when the Go driver is created
 func main() {
-	app := server.NewApp(liquiddb.New())
+	logPath := flag.String("log", "", "write-ahead log file, persistence is disabled when empty")
+	syncPolicy := flag.String("sync", string(liquiddb.SyncEveryWrite), "write-ahead log sync policy: every, batch or interval")
+	memoryLimit := flag.Int64("memory-limit", 0, "approximate memory limit of the data in bytes, 0 for no limit")
+	eviction := flag.String("eviction", string(liquiddb.EvictionReject), "what to do when the memory limit is reached: reject or lru")
+	cachePaths := flag.String("cache", "", "comma separated paths whose children can be evicted by the lru eviction")
+	changeRetention := flag.Int("change-retention", 1000, "amount of the last mutations kept for the clients resuming their subscriptions")
+	changeSets := flag.Bool("change-sets", false, "send the changes of a mutation to the clients as a single changeSet frame")
+	oldValues := flag.Bool("old-values", true, "send the previous values of updated and deleted data to the clients")
+	flag.Parse()
 
 	log.SetFormatter(&log.TextFormatter{})
 	log.SetOutput(os.Stdout)
 	log.SetLevel(log.InfoLevel)
 
+	config := liquiddb.NewConfigBuilder().
+		LogPath(*logPath).
+		SyncPolicy(liquiddb.SyncPolicy(*syncPolicy)).
+		MemoryLimit(*memoryLimit).
+		EvictionPolicy(liquiddb.EvictionPolicy(*eviction)).
+		ChangeRetention(*changeRetention)
+	for _, cache := range strings.Split(*cachePaths, ",") {
+		if cache == "" {
+			continue
+		}
+
+		path, err := liquiddb.ParsePath(cache)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		config.CachePath(path)
+	}
+
+	db, err := liquiddb.NewWithConfig(config.Finalize())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	app := server.NewApp(db).OmitOldValues(!*oldValues).ChangeSets(*changeSets)
+
 	var serversWg sync.WaitGroup
 	//we should exit if any of the servers crashes
 	//at least for now
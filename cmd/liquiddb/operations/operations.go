@@ -10,24 +10,72 @@ type ClientOperation string
 
 const (
 	ClientOperationSet          = ClientOperation("set")
+	ClientOperationSetIf        = ClientOperation("setIf")
+	ClientOperationUpdate       = ClientOperation("update")
+	ClientOperationPatch        = ClientOperation("patch")
+	ClientOperationMergePatch   = ClientOperation("mergePatch")
+	ClientOperationIncrement    = ClientOperation("increment")
+	ClientOperationMin          = ClientOperation("min")
+	ClientOperationMax          = ClientOperation("max")
+	ClientOperationAppend       = ClientOperation("append")
 	ClientOperationDelete       = ClientOperation("delete")
+	ClientOperationDeleteIf     = ClientOperation("deleteIf")
 	ClientOperationGet          = ClientOperation("get")
+	ClientOperationQuery        = ClientOperation("query")
 	ClientOperationSubscribe    = ClientOperation("subscribe")
 	ClientOperationUnSubscribe  = ClientOperation("unsubscribe")
 	HearthbeatOperation         = "hearthbeat"
 	HearthbeatResponseOperation = "hearthbeatResponse"
+	ErrorOperation              = "error"
 )
 
 type OperationClientData struct {
 	ID        uint64          `json:"id,omitempty"`
 	Operation ClientOperation `json:"operation,omitempty"`
-	Path      []string        `json:"path,omitempty"`
+	Path      liquiddb.Path   `json:"path,omitempty"`
 	Value     interface{}     `json:"value,omitempty"`
+	Version   uint64          `json:"version,omitempty"`
+	Query     *liquiddb.Query `json:"query,omitempty"`
 	Timestamp string          `json:"timestamp,omitempty"`
+
+	//Patch is the JSON Patch document of a patch operation
+	Patch []liquiddb.PatchOperation `json:"patch,omitempty"`
+	//Sequence resumes a subscription after the last event the client has seen
+	Sequence *uint64 `json:"sequence,omitempty"`
+}
+
+//OperationQueryData is the result of a query, sent only to the client that made it,
+//Children keeps the order of the query
+type OperationQueryData struct {
+	ID        uint64               `json:"id,omitempty"`
+	Operation ClientOperation      `json:"operation,omitempty"`
+	Path      liquiddb.Path        `json:"path,omitempty"`
+	Children  []liquiddb.EventData `json:"children"`
+}
+
+//OperationPatchData is the result of a patch or a merge patch, sent only to the client that made it
+type OperationPatchData struct {
+	ID        uint64               `json:"id,omitempty"`
+	Operation ClientOperation      `json:"operation,omitempty"`
+	Path      liquiddb.Path        `json:"path,omitempty"`
+	Events    []liquiddb.EventData `json:"events"`
+}
+
+//OperationErrorData is sent to the client whose operation failed
+type OperationErrorData struct {
+	ID         uint64                     `json:"id,omitempty"`
+	Operation  string                     `json:"operation,omitempty"`
+	Path       liquiddb.Path              `json:"path,omitempty"`
+	Error      string                     `json:"error,omitempty"`
+	Version    uint64                     `json:"version,omitempty"`
+	Pattern    liquiddb.Path              `json:"pattern,omitempty"`
+	Violations []liquiddb.SchemaViolation `json:"violations,omitempty"`
 }
 
 type ClientInterest struct {
 	Id        uint64
 	Operation liquiddb.EventOperation
 	Timestamp time.Time
+	//Sequence is set for the resumed interests, they get the events after it regardless of their timestamp
+	Sequence *uint64
 }
@@ -5,8 +5,29 @@ import "github.com/gngeorgiev/liquiddb"
 //App is the app that will be ran when the CLI is started
 type App struct {
 	db *liquiddb.LiquidDb
+
+	//omitOldValues strips the previous values from the events sent to the clients to save bandwidth
+	omitOldValues bool
+
+	//subscriberOptions disconnects the clients which cannot keep up with their events instead of holding back everyone else
+	subscriberOptions liquiddb.SubscriberOptions
 }
 
 func NewApp(db *liquiddb.LiquidDb) *App {
-	return &App{db}
+	return &App{
+		db:                db,
+		subscriberOptions: liquiddb.SubscriberOptions{QueueSize: 1024, Overflow: liquiddb.OverflowDisconnect},
+	}
+}
+
+//ChangeSets sets whether the changes of a mutation are sent to a client as a single changeSet frame
+func (a *App) ChangeSets(enabled bool) *App {
+	a.subscriberOptions.ChangeSets = enabled
+	return a
+}
+
+//OmitOldValues sets whether the events sent to the clients leave out the previous values
+func (a *App) OmitOldValues(omit bool) *App {
+	a.omitOldValues = omit
+	return a
 }
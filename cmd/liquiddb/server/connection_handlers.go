@@ -2,7 +2,6 @@ package server
 
 import (
 	"errors"
-	"strings"
 	"sync"
 	"time"
 
@@ -12,11 +11,12 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+var errSlowClient = errors.New("Client cannot keep up with its events")
+
 //TODO: Use protocol buffers!
 func (a App) handleSocketStoreNotify(conn client_connection.ClientConnection, terminate chan struct{}) error {
-	ch := make(chan liquiddb.EventData, 10)
-	a.db.Notify(ch, liquiddb.EventOperationDelete, liquiddb.EventOperationInsert,
-		liquiddb.EventOperationUpdate, liquiddb.EventOperationGet)
+	//the client handler subscribes the channel to the paths of the interests
+	ch := conn.Events()
 	defer a.db.StopNotify(ch)
 
 	for {
@@ -24,13 +24,27 @@ func (a App) handleSocketStoreNotify(conn client_connection.ClientConnection, te
 		select {
 		case <-terminate:
 			return nil
-		case op := <-ch:
-			//TODO: more strings.Join to optimize....
-			//I should probably just keep path in both forms - string and slice
-			send, err := conn.WriteInterested(strings.Join(op.Path, "."), op)
+		case op, ok := <-ch:
+			if !ok {
+				log.WithField("category", "notify").Error(errSlowClient)
+				return errSlowClient
+			}
+
+			var ev liquiddb.EventData
+			var send bool
+			var err error
+			if op.Operation == liquiddb.EventOperationChangeSet {
+				ev, send, err = a.interestedChanges(conn, op)
+			} else {
+				ev, send, err = conn.WriteInterested(op)
+				if a.omitOldValues {
+					ev.OldValue = nil
+				}
+			}
+
 			if send {
-				log.WithField("data", op).Debug("Sending data")
-				err = conn.WriteJSON(op)
+				log.WithField("data", ev).Debug("Sending data")
+				err = conn.WriteJSON(ev)
 			} else {
 				log.WithField("operation", op).Debug("Did not send data because not interested")
 			}
@@ -43,6 +57,139 @@ func (a App) handleSocketStoreNotify(conn client_connection.ClientConnection, te
 	}
 }
 
+//interestedChanges leaves the changes the connection is interested in in a change set,
+//it is sent only when some of them are left
+func (a App) interestedChanges(conn client_connection.ClientConnection, changeSet liquiddb.EventData) (liquiddb.EventData, bool, error) {
+	changes := make([]liquiddb.EventData, 0, len(changeSet.Changes))
+	paths := make(map[string]bool)
+	for _, change := range changeSet.Changes {
+		ev, send, err := conn.WriteInterested(change)
+		if err != nil {
+			return changeSet, false, err
+		}
+
+		if send {
+			if a.omitOldValues {
+				ev.OldValue = nil
+			}
+
+			changes = append(changes, ev)
+			paths[ev.Path.String()] = true
+		}
+	}
+
+	leaves := make([]liquiddb.Path, 0)
+	for _, leaf := range changeSet.Leaves {
+		if paths[leaf.String()] {
+			leaves = append(leaves, leaf)
+		}
+	}
+
+	changeSet.Changes = changes
+	changeSet.Leaves = leaves
+	return changeSet, len(changes) > 0, nil
+}
+
+//subscribe sends the events of the interest to the connection, a subscription with a sequence number
+//gets the retained events after it first
+func (a App) subscribe(conn client_connection.ClientConnection, data operations.OperationClientData, op liquiddb.EventOperation) error {
+	//the events of pattern interests are matched against the pattern when they are written
+	prefix := data.Path.Scope()
+	if data.Sequence != nil {
+		return a.db.NotifyPathFrom(conn.Events(), prefix, *data.Sequence, a.subscriberOptions, op)
+	}
+
+	return a.db.NotifyPathWithOptions(conn.Events(), prefix, a.subscriberOptions, op)
+}
+
+//writeOperationError lets the client know that its operation failed,
+//the returned error is the one of the write to the connection
+func writeOperationError(conn client_connection.ClientConnection, data operations.OperationClientData, opErr error) error {
+	if opErr == nil {
+		return nil
+	}
+
+	errData := operations.OperationErrorData{
+		ID:        data.ID,
+		Operation: operations.ErrorOperation,
+		Path:      data.Path,
+		Error:     opErr.Error(),
+	}
+
+	switch e := opErr.(type) {
+	case *liquiddb.VersionConflictError:
+		errData.Version = e.Actual
+	case *liquiddb.SchemaError:
+		errData.Pattern = e.Pattern
+		errData.Violations = e.Violations
+	}
+
+	log.WithFields(log.Fields{
+		"category":  "operation",
+		"operation": data.Operation,
+	}).Debug(opErr)
+
+	return conn.WriteJSON(errData)
+}
+
+func (a App) handleQuery(conn client_connection.ClientConnection, data operations.OperationClientData) error {
+	query := liquiddb.Query{}
+	if data.Query != nil {
+		query = *data.Query
+	}
+
+	children, err := a.db.Link(data.ID).Query(data.Path, query)
+	if err != nil {
+		return writeOperationError(conn, data, err)
+	}
+
+	return conn.WriteJSON(operations.OperationQueryData{
+		ID:        data.ID,
+		Operation: operations.ClientOperationQuery,
+		Path:      data.Path,
+		Children:  children,
+	})
+}
+
+func (a App) handlePatch(conn client_connection.ClientConnection, data operations.OperationClientData) error {
+	var events []liquiddb.EventData
+	var err error
+	if data.Operation == operations.ClientOperationPatch {
+		events, err = a.db.Link(data.ID).ApplyPatch(data.Path, data.Patch)
+	} else {
+		events, err = a.db.Link(data.ID).ApplyMergePatch(data.Path, data.Value)
+	}
+
+	if err != nil {
+		return writeOperationError(conn, data, err)
+	}
+
+	return conn.WriteJSON(operations.OperationPatchData{
+		ID:        data.ID,
+		Operation: data.Operation,
+		Path:      data.Path,
+		Events:    events,
+	})
+}
+
+func (a App) handleAtomic(conn client_connection.ClientConnection, data operations.OperationClientData) error {
+	db := a.db.Link(data.ID)
+
+	var err error
+	switch data.Operation {
+	case operations.ClientOperationIncrement:
+		_, err = db.Increment(data.Path, data.Value)
+	case operations.ClientOperationMin:
+		_, err = db.Min(data.Path, data.Value)
+	case operations.ClientOperationMax:
+		_, err = db.Max(data.Path, data.Value)
+	case operations.ClientOperationAppend:
+		_, err = db.Append(data.Path, data.Value)
+	}
+
+	return writeOperationError(conn, data, err)
+}
+
 func (a App) handleSocketClient(conn client_connection.ClientConnection, terminate chan struct{}) error {
 	dataCh := make(chan operations.OperationClientData, 10)
 	errorCh := make(chan error)
@@ -73,22 +220,69 @@ func (a App) handleSocketClient(conn client_connection.ClientConnection, termina
 
 			switch data.Operation {
 			case operations.ClientOperationSet:
-				a.db.Link(data.ID).SetPath(data.Path, data.Value)
+				_, err := a.db.Link(data.ID).SetPath(data.Path, data.Value)
+				if err := writeOperationError(conn, data, err); err != nil {
+					return err
+				}
+			case operations.ClientOperationSetIf:
+				_, err := a.db.Link(data.ID).SetPathIf(data.Path, data.Value, data.Version)
+				if err := writeOperationError(conn, data, err); err != nil {
+					return err
+				}
+			case operations.ClientOperationUpdate:
+				_, err := a.db.Link(data.ID).Update(data.Path, data.Value)
+				if err := writeOperationError(conn, data, err); err != nil {
+					return err
+				}
+			case operations.ClientOperationPatch, operations.ClientOperationMergePatch:
+				if err := a.handlePatch(conn, data); err != nil {
+					return err
+				}
+			case operations.ClientOperationIncrement, operations.ClientOperationMin,
+				operations.ClientOperationMax, operations.ClientOperationAppend:
+				if err := a.handleAtomic(conn, data); err != nil {
+					return err
+				}
 			case operations.ClientOperationDelete:
-				a.db.Link(data.ID).Delete(data.Path)
+				//deleting a missing path is not an error for the clients
+				_, err := a.db.Link(data.ID).TryDelete(data.Path)
+				if err == liquiddb.ErrNotFound {
+					err = nil
+				}
+
+				if err := writeOperationError(conn, data, err); err != nil {
+					return err
+				}
+			case operations.ClientOperationDeleteIf:
+				_, err := a.db.Link(data.ID).DeleteIf(data.Path, data.Version)
+				if err := writeOperationError(conn, data, err); err != nil {
+					return err
+				}
 			case operations.ClientOperationGet:
 				a.db.Link(data.ID).Get(data.Path)
+			case operations.ClientOperationQuery:
+				if err := a.handleQuery(conn, data); err != nil {
+					return err
+				}
 			case operations.ClientOperationSubscribe:
 				op := liquiddb.EventOperation(data.Value.(string))
-				//TODO: can we optimize this strings join?
-				if err := conn.AddInterest(strings.Join(data.Path, "."), op, data); err != nil {
+				if err := conn.AddInterest(data.Path, op, data); err != nil {
 					log.WithField("category", "add interest").Error(err)
 					return err
 				}
+
+				if err := a.subscribe(conn, data, op); err != nil {
+					log.WithField("category", "add interest").Error(err)
+					conn.RemoveInterest(data.Path, op, data)
+					if err := writeOperationError(conn, data, err); err != nil {
+						return err
+					}
+				}
 			case operations.ClientOperationUnSubscribe:
 				op := liquiddb.EventOperation(data.Value.(string))
-				//TODO: can we optimize this strings join?
-				conn.RemoveInterest(strings.Join(data.Path, "."), op, data)
+				for i := conn.RemoveInterest(data.Path, op, data); i > 0; i-- {
+					a.db.StopNotifyPath(conn.Events(), data.Path.Scope(), op)
+				}
 			case operations.HearthbeatResponseOperation:
 				conn.HearthbeatResponse() <- struct{}{}
 			default:
@@ -248,5 +442,7 @@ func (a App) dbConnectionHandler(conn client_connection.ClientConnection) {
 
 	wg.Wait()
 	close(closeConnection)
+	//the client handler might have subscribed after the notify handler returned
+	a.db.StopNotify(conn.Events())
 	log.WithField("connection", conn.String()).Info("Closed connection")
 }
@@ -0,0 +1,211 @@
+package liquiddb
+
+import (
+	"time"
+)
+
+//SyncPolicy controls when the write-ahead log is flushed to stable storage
+type SyncPolicy string
+
+const (
+	//SyncEveryWrite flushes the log after every mutation
+	SyncEveryWrite = SyncPolicy("every")
+	//SyncBatch flushes the log once every Config.SyncBatchSize mutations
+	SyncBatch = SyncPolicy("batch")
+	//SyncInterval flushes the log in the background every Config.SyncInterval
+	SyncInterval = SyncPolicy("interval")
+)
+
+//defaultInterval is the SyncInterval and the ReapInterval of a Config which does not set a positive one
+const defaultInterval = time.Second
+
+//EvictionPolicy controls what happens to a write that would exceed a memory limit
+type EvictionPolicy string
+
+const (
+	//EvictionReject rejects the write with a QuotaError
+	EvictionReject = EvictionPolicy("reject")
+	//EvictionLRU deletes the least recently accessed subtrees under the cache paths to make room for the write,
+	//the write is rejected with a QuotaError when they do not free enough memory
+	EvictionLRU = EvictionPolicy("lru")
+)
+
+//ConfigBuilder builds a Config, every option that is not set gets its default value
+type ConfigBuilder struct {
+	logPath       *string
+	syncPolicy    *SyncPolicy
+	syncBatchSize *int
+	syncInterval  *time.Duration
+	reapInterval  *time.Duration
+
+	memoryLimit     *int64
+	keyMemoryLimits map[string]int64
+	evictionPolicy  *EvictionPolicy
+	cachePaths      []Path
+
+	changeRetention *int
+}
+
+//Config holds the options of a LiquidDb instance
+type Config struct {
+	//LogPath is the file of the write-ahead log, persistence is disabled when it is empty
+	LogPath       string
+	SyncPolicy    SyncPolicy
+	SyncBatchSize int
+	//SyncInterval is the time between flushes for SyncInterval, a second when it is not positive
+	SyncInterval time.Duration
+	//ReapInterval is how often the nodes whose TTL ran out are deleted, every second when it is not positive
+	ReapInterval time.Duration
+
+	//MemoryLimit is the approximate amount of bytes the whole tree may use, 0 for no limit
+	MemoryLimit int64
+	//KeyMemoryLimits are the limits of the subtrees of top level keys
+	KeyMemoryLimits map[string]int64
+	EvictionPolicy  EvictionPolicy
+	//CachePaths are the paths whose children can be evicted by EvictionLRU
+	CachePaths []Path
+
+	//ChangeRetention is the amount of the last mutations whose events are kept for Changes
+	ChangeRetention int
+}
+
+//NewConfigBuilder creates a new ConfigBuilder
+func NewConfigBuilder() *ConfigBuilder {
+	return &ConfigBuilder{}
+}
+
+//LogPath enables persistence by writing every mutation to a write-ahead log at path
+func (c *ConfigBuilder) LogPath(path string) *ConfigBuilder {
+	c.logPath = &path
+	return c
+}
+
+//SyncPolicy sets when the write-ahead log is flushed to stable storage
+func (c *ConfigBuilder) SyncPolicy(policy SyncPolicy) *ConfigBuilder {
+	c.syncPolicy = &policy
+	return c
+}
+
+//SyncBatchSize sets the amount of mutations between flushes for SyncBatch
+func (c *ConfigBuilder) SyncBatchSize(size int) *ConfigBuilder {
+	c.syncBatchSize = &size
+	return c
+}
+
+//SyncInterval sets the time between flushes for SyncInterval
+func (c *ConfigBuilder) SyncInterval(interval time.Duration) *ConfigBuilder {
+	c.syncInterval = &interval
+	return c
+}
+
+//ReapInterval sets how often the nodes whose TTL ran out are deleted
+func (c *ConfigBuilder) ReapInterval(interval time.Duration) *ConfigBuilder {
+	c.reapInterval = &interval
+	return c
+}
+
+//MemoryLimit sets the approximate amount of bytes the whole tree may use
+func (c *ConfigBuilder) MemoryLimit(bytes int64) *ConfigBuilder {
+	c.memoryLimit = &bytes
+	return c
+}
+
+//KeyMemoryLimit sets the approximate amount of bytes the subtree of a top level key may use
+func (c *ConfigBuilder) KeyMemoryLimit(key string, bytes int64) *ConfigBuilder {
+	if c.keyMemoryLimits == nil {
+		c.keyMemoryLimits = make(map[string]int64)
+	}
+
+	c.keyMemoryLimits[key] = bytes
+	return c
+}
+
+//EvictionPolicy sets what happens to a write that would exceed a memory limit
+func (c *ConfigBuilder) EvictionPolicy(policy EvictionPolicy) *ConfigBuilder {
+	c.evictionPolicy = &policy
+	return c
+}
+
+//CachePath marks path as a cache, its children can be evicted by EvictionLRU
+func (c *ConfigBuilder) CachePath(path Path) *ConfigBuilder {
+	c.cachePaths = append(c.cachePaths, path)
+	return c
+}
+
+//ChangeRetention sets the amount of the last mutations whose events are kept for Changes
+func (c *ConfigBuilder) ChangeRetention(mutations int) *ConfigBuilder {
+	c.changeRetention = &mutations
+	return c
+}
+
+//Finalize creates the Config
+func (c *ConfigBuilder) Finalize() Config {
+	config := Config{}
+
+	if c.logPath != nil {
+		config.LogPath = *c.logPath
+	}
+
+	if c.syncPolicy != nil {
+		config.SyncPolicy = *c.syncPolicy
+	} else {
+		config.SyncPolicy = SyncEveryWrite
+	}
+
+	if c.syncBatchSize != nil {
+		config.SyncBatchSize = *c.syncBatchSize
+	} else {
+		config.SyncBatchSize = 100
+	}
+
+	if c.syncInterval != nil {
+		config.SyncInterval = *c.syncInterval
+	} else {
+		config.SyncInterval = defaultInterval
+	}
+
+	if c.reapInterval != nil {
+		config.ReapInterval = *c.reapInterval
+	} else {
+		config.ReapInterval = defaultInterval
+	}
+
+	if c.memoryLimit != nil {
+		config.MemoryLimit = *c.memoryLimit
+	}
+
+	config.KeyMemoryLimits = make(map[string]int64)
+	for key, limit := range c.keyMemoryLimits {
+		config.KeyMemoryLimits[key] = limit
+	}
+
+	if c.evictionPolicy != nil {
+		config.EvictionPolicy = *c.evictionPolicy
+	} else {
+		config.EvictionPolicy = EvictionReject
+	}
+
+	config.CachePaths = append([]Path{}, c.cachePaths...)
+
+	if c.changeRetention != nil {
+		config.ChangeRetention = *c.changeRetention
+	} else {
+		config.ChangeRetention = 1000
+	}
+
+	return config
+}
+
+//withDefaults returns the config with the default in place of every interval which is not positive,
+//a ticker cannot be created for them
+func (c Config) withDefaults() Config {
+	if c.SyncInterval <= 0 {
+		c.SyncInterval = defaultInterval
+	}
+
+	if c.ReapInterval <= 0 {
+		c.ReapInterval = defaultInterval
+	}
+
+	return c
+}
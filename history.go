@@ -0,0 +1,480 @@
+package liquiddb
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+var (
+	//ErrInvalidHistoryOptions is returned when a history has neither a limit nor a retention
+	ErrInvalidHistoryOptions = errors.New("Invalid history options")
+	//ErrHistoryNotEnabled is returned when there is no history for a path
+	ErrHistoryNotEnabled = errors.New("History not enabled")
+	//ErrHistoryUnavailable is returned when the history of a path does not reach back to a timestamp
+	ErrHistoryUnavailable = errors.New("History unavailable")
+)
+
+//HistoryOptions bound the history of a subtree, when both are set the stricter one wins
+type HistoryOptions struct {
+	//Limit is the amount of recent values that are kept
+	Limit int
+	//Retention is how long a value is kept after it was replaced
+	Retention time.Duration
+}
+
+//historyChange is the value a write left at a path inside the subtree of a history, relative to it
+type historyChange struct {
+	path   Path
+	value  interface{}
+	exists bool
+}
+
+//historyEntry holds the changes a write made to a subtree, the oldest entry
+//holds a single change with the whole subtree as it was at its time
+type historyEntry struct {
+	timestamp time.Time
+	id        uint64
+	version   uint64
+	changes   []historyChange
+}
+
+//history keeps the recent changes of the subtree at path, oldest first
+type history struct {
+	path    Path
+	options HistoryOptions
+	entries []historyEntry
+}
+
+//touchedBy returns whether an event at path can change the subtree of the history
+func (h *history) touchedBy(path Path) bool {
+	return hasPathPrefix(path, h.path) || hasPathPrefix(h.path, path)
+}
+
+func (h *history) add(entry historyEntry) {
+	h.entries = append(h.entries, entry)
+	h.trim(entry.timestamp)
+}
+
+//trim drops the values beyond the limit and the ones replaced before the retention, the current value is always kept.
+//The changes of the dropped entries are folded into the oldest one that is kept.
+func (h *history) trim(now time.Time) {
+	drop := 0
+	if h.options.Limit > 0 && len(h.entries) > h.options.Limit {
+		drop = len(h.entries) - h.options.Limit
+	}
+
+	if h.options.Retention > 0 {
+		for drop < len(h.entries)-1 && now.Sub(h.entries[drop+1].timestamp) > h.options.Retention {
+			drop++
+		}
+	}
+
+	if drop == 0 {
+		return
+	}
+
+	//the value of the oldest entry is owned by the history, so it is changed in place
+	base := h.entries[0].changes[0]
+	for _, entry := range h.entries[1 : drop+1] {
+		for _, change := range entry.changes {
+			base.value, base.exists = setValue(base.value, base.exists, change.path, change.value, change.exists)
+		}
+	}
+
+	oldest := h.entries[drop]
+	oldest.changes = []historyChange{base}
+	h.entries = append([]historyEntry{oldest}, h.entries[drop+1:]...)
+}
+
+//at returns the index of the entry that was current at timestamp
+func (h *history) at(timestamp time.Time) (int, bool) {
+	i := sort.Search(len(h.entries), func(i int) bool {
+		return h.entries[i].timestamp.After(timestamp)
+	})
+
+	return i - 1, i > 0
+}
+
+//changes returns the values a write left at the paths inside the subtree that its events changed,
+//none of the paths is inside another one
+func (h *history) changes(t *tree, events []EventData) []historyChange {
+	deleted := make(map[string]bool)
+	written := make(map[string]bool)
+	for _, ev := range events {
+		path := ev.Path.Relative()
+		if ev.Operation == EventOperationDelete {
+			deleted[path.String()] = true
+			continue
+		}
+
+		for i := range path {
+			written[path[:i].String()] = true
+		}
+	}
+
+	paths := make([]Path, 0)
+	for _, ev := range events {
+		path := ev.Path.Relative()
+		if ev.Operation == EventOperationDelete {
+			//the delete of a node covers the ones of its descendants
+			if len(path) > 0 && deleted[path[:len(path)-1].String()] {
+				continue
+			}
+		} else if written[path.String()] {
+			//only the version of an ancestor of a written node changed
+			continue
+		}
+
+		//an element of an array moves the ones after it, so the whole array is kept
+		if ev.Index != nil {
+			path = path[:len(path)-1]
+		}
+
+		switch {
+		case hasPathPrefix(path, h.path):
+		case hasPathPrefix(h.path, path):
+			path = h.path
+		default:
+			continue
+		}
+
+		paths = append(paths, path)
+	}
+
+	changes := make([]historyChange, 0)
+	for _, path := range outermostPaths(paths) {
+		change := historyChange{path: path[len(h.path):]}
+		if node := t.findNode(path, false); node != nil {
+			change.value = t.jsonValue(node)
+			change.exists = true
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+//valueAfter returns the value at the relative path inside the subtree as it was after the entry i,
+//the value is a copy the caller can keep
+func (h *history) valueAfter(i int, path Path) (interface{}, bool) {
+	var value interface{}
+	exists := false
+	for _, entry := range h.entries[:i+1] {
+		value, exists = applyChanges(value, exists, path, entry.changes)
+	}
+
+	return value, exists
+}
+
+//applyChanges applies the changes to the value at the relative path, the changes outside of it are skipped
+func applyChanges(value interface{}, exists bool, path Path, changes []historyChange) (interface{}, bool) {
+	for _, change := range changes {
+		switch {
+		case hasPathPrefix(path, change.path):
+			value, exists = nil, false
+			if change.exists {
+				if v, ok := valueAt(change.value, path[len(change.path):]); ok {
+					value, exists = copyValue(v), true
+				}
+			}
+		case hasPathPrefix(change.path, path):
+			value, exists = setValue(value, exists, change.path[len(path):], copyValue(change.value), change.exists)
+		}
+	}
+
+	return value, exists
+}
+
+//setValue sets the value at path inside current, or deletes it when it does not exist. The containers of
+//current are changed in place, the ones that are missing on the way are created as objects.
+func setValue(current interface{}, currentExists bool, path Path, value interface{}, exists bool) (interface{}, bool) {
+	if len(path) == 0 {
+		return value, exists
+	}
+
+	key, rest := path[0], path[1:]
+	switch c := current.(type) {
+	case map[string]interface{}:
+		child, ok := c[key]
+		if child, ok = setValue(child, ok, rest, value, exists); ok {
+			c[key] = child
+		} else {
+			delete(c, key)
+		}
+
+		return c, true
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		switch {
+		case err != nil || i < 0 || i > len(c):
+		case i == len(c):
+			if child, ok := setValue(nil, false, rest, value, exists); ok {
+				c = append(c, child)
+			}
+		default:
+			if child, ok := setValue(c[i], true, rest, value, exists); ok {
+				c[i] = child
+			} else {
+				c = append(c[:i], c[i+1:]...)
+			}
+		}
+
+		return c, true
+	}
+
+	if !exists {
+		return current, currentExists
+	}
+
+	return setValue(map[string]interface{}{}, true, path, value, exists)
+}
+
+//copyValue returns a deep copy of a json value
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			res[key] = copyValue(child)
+		}
+
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, child := range v {
+			res[i] = copyValue(child)
+		}
+
+		return res
+	}
+
+	return value
+}
+
+//historySet holds the histories of a tree, it is guarded by the lock of the tree
+type historySet struct {
+	histories map[string]*history
+}
+
+func newHistorySet() *historySet {
+	return &historySet{
+		histories: make(map[string]*history),
+	}
+}
+
+//find returns the history of the closest subtree holding path
+func (s *historySet) find(path Path) *history {
+	path = path.Relative()
+	for i := len(path); i >= 0; i-- {
+		if h, ok := s.histories[path[:i].String()]; ok {
+			return h
+		}
+	}
+
+	return nil
+}
+
+//record adds the changes the events of a write made to the subtrees with a history
+func (s *historySet) record(t *tree, events []EventData, id uint64, now time.Time) {
+	for _, h := range s.histories {
+		touched := false
+		for _, ev := range events {
+			if touched = h.touchedBy(ev.Path); touched {
+				break
+			}
+		}
+
+		if !touched {
+			continue
+		}
+
+		if changes := h.changes(t, events); len(changes) > 0 {
+			h.add(historyEntry{
+				timestamp: now,
+				id:        id,
+				version:   t.currentRevision(),
+				changes:   changes,
+			})
+		}
+	}
+}
+
+func (s *historySet) enable(t *tree, path Path, options HistoryOptions) error {
+	if options.Limit <= 0 && options.Retention <= 0 {
+		return ErrInvalidHistoryOptions
+	}
+
+	path = path.Relative()
+	h := &history{path: path, options: options}
+	base := historyChange{path: Path{}}
+	if node := t.findNode(path, false); node != nil {
+		base.value = t.jsonValue(node)
+		base.exists = true
+	}
+
+	h.add(historyEntry{
+		timestamp: time.Now().UTC(),
+		version:   t.currentRevision(),
+		changes:   []historyChange{base},
+	})
+
+	s.histories[path.String()] = h
+	return nil
+}
+
+func (s *historySet) disable(path Path) error {
+	key := path.Relative().String()
+	if _, ok := s.histories[key]; !ok {
+		return ErrHistoryNotEnabled
+	}
+
+	delete(s.histories, key)
+	return nil
+}
+
+//valueAt returns the value at the relative path inside a json value
+func valueAt(value interface{}, path Path) (interface{}, bool) {
+	for _, key := range path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			child, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+
+			value = child
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+//relative returns path relative to the subtree of h
+func (h *history) relative(path Path) Path {
+	return path.Relative()[len(h.path):]
+}
+
+//pathKey returns the key of the node at path, the root has its own key
+func pathKey(path Path) string {
+	if len(path.Relative()) == 0 {
+		return TreeRoot
+	}
+
+	return path[len(path)-1]
+}
+
+//EnableHistory starts keeping the values the subtree at path has after every change to it,
+//bounded by options. The history is kept in memory only.
+func (db LiquidDb) EnableHistory(path Path, options HistoryOptions) error {
+	db.treeMutex.Lock()
+	defer db.treeMutex.Unlock()
+
+	return db.tree.histories.enable(db.tree, path, options)
+}
+
+//DisableHistory stops keeping the history of the subtree at path and drops it
+func (db LiquidDb) DisableHistory(path Path) error {
+	db.treeMutex.Lock()
+	defer db.treeMutex.Unlock()
+
+	return db.tree.histories.disable(path)
+}
+
+//GetAt gets the value at path as it was at timestamp, the path must be in a subtree with an enabled history
+func (db LiquidDb) GetAt(path Path, timestamp time.Time) (EventData, error) {
+	db.treeMutex.RLock()
+	defer db.treeMutex.RUnlock()
+
+	h := db.tree.histories.find(path)
+	if h == nil {
+		return EventData{}, ErrHistoryNotEnabled
+	}
+
+	i, ok := h.at(timestamp)
+	if !ok {
+		return EventData{}, ErrHistoryUnavailable
+	}
+
+	entry := h.entries[i]
+	value, _ := h.valueAfter(i, h.relative(path))
+	return EventData{
+		ID:        entry.id,
+		Operation: EventOperationGet,
+		Path:      path,
+		Key:       pathKey(path),
+		Value:     value,
+		Version:   entry.version,
+		Timestamp: entry.timestamp,
+	}, nil
+}
+
+//History returns up to limit of the most recent values at path, newest first, with the time
+//and the link ID of the write that set them. Deleted values are returned as delete events.
+//A limit of 0 returns the whole history.
+func (db LiquidDb) History(path Path, limit int) ([]EventData, error) {
+	db.treeMutex.RLock()
+	defer db.treeMutex.RUnlock()
+
+	h := db.tree.histories.find(path)
+	if h == nil {
+		return nil, ErrHistoryNotEnabled
+	}
+
+	//the values after every entry are rebuilt from the oldest one forward, only the
+	//entries that changed the value at path are part of its history
+	relative := h.relative(path)
+	values := make([]interface{}, len(h.entries))
+	exists := make([]bool, len(h.entries))
+	changed := make([]bool, len(h.entries))
+	var value interface{}
+	var valueExists bool
+	for i, entry := range h.entries {
+		value, valueExists = applyChanges(value, valueExists, relative, entry.changes)
+		changed[i] = i == 0 || valueExists != exists[i-1] || !reflect.DeepEqual(value, values[i-1])
+		if changed[i] {
+			values[i], exists[i] = copyValue(value), valueExists
+		} else {
+			values[i], exists[i] = values[i-1], exists[i-1]
+		}
+	}
+
+	res := make([]EventData, 0)
+	for i := len(h.entries) - 1; i >= 0 && (limit <= 0 || len(res) < limit); i-- {
+		if !changed[i] {
+			continue
+		}
+
+		entry, value := h.entries[i], values[i]
+
+		op := EventOperationUpdate
+		if !exists[i] {
+			op = EventOperationDelete
+		}
+
+		res = append(res, EventData{
+			ID:        entry.id,
+			Operation: op,
+			Path:      path,
+			Key:       pathKey(path),
+			Value:     value,
+			Version:   entry.version,
+			Timestamp: entry.timestamp,
+		})
+	}
+
+	return res, nil
+}
@@ -0,0 +1,164 @@
+package liquiddb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var account = Path{"accounts", "a1"}
+
+func TestHistory_GetAt(t *testing.T) {
+	db := New()
+	db.SetPath(account, map[string]interface{}{"balance": 10})
+
+	if err := db.EnableHistory(account, HistoryOptions{Limit: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	balance := append(append(Path{}, account...), "balance")
+	before := time.Now().UTC()
+	time.Sleep(time.Millisecond)
+	db.Link(7).SetPath(balance, 20)
+	time.Sleep(time.Millisecond)
+	middle := time.Now().UTC()
+	time.Sleep(time.Millisecond)
+	db.Delete(account)
+
+	if v, err := db.GetAt(balance, before); err != nil || v.Value != 10 {
+		t.Fatalf("Invalid value before the change %+v %v", v, err)
+	}
+
+	if v, _ := db.GetAt(balance, middle); v.Value != 20 || v.ID != 7 {
+		t.Fatalf("Invalid value after the change %+v", v)
+	}
+
+	if v, _ := db.GetAt(balance, time.Now().UTC()); v.Value != nil {
+		t.Fatalf("Invalid value after the delete %+v", v)
+	}
+
+	if _, err := db.GetAt(balance, before.Add(-time.Hour)); err != ErrHistoryUnavailable {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if _, err := db.GetAt(Path{"other"}, before); err != ErrHistoryNotEnabled {
+		t.Fatalf("Invalid error %v", err)
+	}
+}
+
+func TestHistory_History(t *testing.T) {
+	db := New()
+	db.EnableHistory(account, HistoryOptions{Limit: 4})
+
+	name := append(append(Path{}, account...), "name")
+	for _, v := range []string{"a", "b", "c", "d"} {
+		db.SetPath(name, v)
+		//a change beside the name is not part of its history
+		db.SetPath(append(append(Path{}, account...), "other"), v)
+	}
+
+	res, err := db.History(name, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 2 || res[0].Value != "d" || res[1].Value != "c" {
+		t.Fatalf("Invalid history %+v", res)
+	}
+
+	if res, _ := db.History(name, 1); len(res) != 1 || res[0].Value != "d" {
+		t.Fatalf("Invalid limited history %+v", res)
+	}
+
+	if err := db.DisableHistory(account); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.History(name, 0); err != ErrHistoryNotEnabled {
+		t.Fatalf("Invalid error %v", err)
+	}
+}
+
+func TestHistory_Retention(t *testing.T) {
+	db := New()
+	if err := db.EnableHistory(account, HistoryOptions{}); err != ErrInvalidHistoryOptions {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	db.EnableHistory(account, HistoryOptions{Retention: 10 * time.Millisecond})
+	db.SetPath(account, 1)
+	time.Sleep(20 * time.Millisecond)
+	db.SetPath(account, 2)
+
+	res, _ := db.History(account, 0)
+	if len(res) != 2 || res[0].Value != 2 || res[1].Value != 1 {
+		t.Fatalf("Invalid retained history %+v", res)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	db.SetPath(account, 3)
+
+	res, _ = db.History(account, 0)
+	if len(res) != 2 || res[0].Value != 3 || res[1].Value != 2 {
+		t.Fatalf("Invalid retained history %+v", res)
+	}
+}
+
+func TestHistory_Root(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	if err := db.EnableHistory(Path{}, HistoryOptions{Limit: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetPath(Path{"a"}, 1)
+	db.SetPath(Path{"b"}, 2)
+
+	v, err := db.GetAt(Path{}, time.Now().UTC())
+	if err != nil || v.Key != TreeRoot || !reflect.DeepEqual(v.Value, map[string]interface{}{"a": 1, "b": 2}) {
+		t.Fatalf("Invalid root %+v %v", v, err)
+	}
+
+	if res, _ := db.History(Path{TreeRoot}, 1); len(res) != 1 || res[0].Key != TreeRoot {
+		t.Fatalf("Invalid root history %+v", res)
+	}
+}
+
+func TestHistory_Changes(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	db.SetPath(account, map[string]interface{}{"name": "a", "tags": []interface{}{"x", "y"}, "other": 1})
+	db.EnableHistory(account, HistoryOptions{Limit: 4})
+
+	name := append(append(Path{}, account...), "name")
+	tags := append(append(Path{}, account...), "tags")
+	db.SetPath(name, "b")
+	db.Delete(append(append(Path{}, tags...), "0"))
+	db.Update(account, map[string]interface{}{"other": nil})
+
+	//only the changed values are kept, an array is kept whole since its elements move
+	h := db.tree.histories.find(account)
+	expected := [][]historyChange{
+		{{path: Path{"name"}, value: "b", exists: true}},
+		{{path: Path{"tags"}, value: []interface{}{"y"}, exists: true}},
+		{{path: Path{"other"}}},
+	}
+	for i, changes := range expected {
+		if !reflect.DeepEqual(h.entries[i+1].changes, changes) {
+			t.Fatalf("Invalid changes %+v", h.entries[i+1].changes)
+		}
+	}
+
+	//the first change is folded into the oldest value
+	db.SetPath(name, "c")
+	folded := map[string]interface{}{"name": "b", "tags": []interface{}{"x", "y"}, "other": 1}
+	if v, _ := db.GetAt(account, h.entries[0].timestamp); !reflect.DeepEqual(v.Value, folded) {
+		t.Fatalf("Invalid folded value %+v", v.Value)
+	}
+
+	if res, _ := db.History(tags, 0); len(res) != 2 || !reflect.DeepEqual(res[0].Value, []interface{}{"y"}) {
+		t.Fatalf("Invalid history %+v", res)
+	}
+}
@@ -0,0 +1,239 @@
+package liquiddb
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/go-errors/errors"
+)
+
+var (
+	//ErrIndexExists is returned when creating an index that is already defined
+	ErrIndexExists = errors.New("Index already exists")
+	//ErrIndexNotFound is returned when dropping an index that is not defined
+	ErrIndexNotFound = errors.New("Index not found")
+)
+
+//IndexDefinition describes an index of the children of Path by the value of their descendant at Field
+type IndexDefinition struct {
+	Path  Path `json:"path"`
+	Field Path `json:"field"`
+}
+
+func (d IndexDefinition) name() string {
+	return strings.Join(d.Path, "\x00") + "\x01" + strings.Join(d.Field, "\x00")
+}
+
+type indexEntry struct {
+	key   string
+	value interface{}
+}
+
+//compareIndexEntries orders entries the same way a Query ordered by child does
+func compareIndexEntries(a, b indexEntry) int {
+	if c := compareValues(a.value, b.value); c != 0 {
+		return c
+	}
+
+	return compareKeys(a.key, b.key)
+}
+
+//index keeps the children of a node sorted by the value of a field,
+//every child is in the index, the ones without the field with a nil value.
+//The entries are kept in a balanced tree, so a write changing a child costs O(log n).
+type index struct {
+	definition IndexDefinition
+
+	entries *entryTree
+	values  map[string]interface{}
+}
+
+func (idx *index) remove(key string) {
+	value, ok := idx.values[key]
+	if !ok {
+		return
+	}
+
+	delete(idx.values, key)
+	idx.entries = idx.entries.remove(indexEntry{key, value})
+}
+
+func (idx *index) insert(key string, value interface{}) {
+	idx.entries = idx.entries.insert(indexEntry{key, value})
+	idx.values[key] = value
+}
+
+//refresh reindexes the child with key of parent
+func (idx *index) refresh(t *tree, parent *Node, key string) {
+	idx.remove(key)
+	if parent == nil {
+		return
+	}
+
+	child, ok := parent.child(key)
+	if !ok {
+		return
+	}
+
+	var value interface{}
+	if field := t.findDescendant(child, idx.definition.Field); field != nil {
+		value = t.jsonValue(field)
+	}
+
+	idx.insert(key, value)
+}
+
+//bounds returns the range of entries whose values are between start and end, nil bounds are unbounded
+func (idx *index) bounds(start, end interface{}) (int, int) {
+	from, to := 0, idx.entries.len()
+	if start != nil {
+		from = idx.entries.search(func(entry indexEntry) bool {
+			return compareValues(entry.value, start) >= 0
+		})
+	}
+
+	if end != nil {
+		to = idx.entries.search(func(entry indexEntry) bool {
+			return compareValues(entry.value, end) > 0
+		})
+	}
+
+	if to < from {
+		to = from
+	}
+
+	return from, to
+}
+
+//indexSet holds the indexes of a tree, it is guarded by the lock of the tree
+type indexSet struct {
+	indexes map[string]*index
+}
+
+func newIndexSet() *indexSet {
+	return &indexSet{
+		indexes: make(map[string]*index),
+	}
+}
+
+//trimRoot removes the optional TreeRoot key at the beginning of a path
+func trimRoot(path Path) Path {
+	if len(path) > 0 && path[0] == TreeRoot {
+		return path[1:]
+	}
+
+	return path
+}
+
+func hasPathPrefix(path, prefix []string) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+
+	for i, key := range prefix {
+		if path[i] != key {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *indexSet) find(path, field Path) *index {
+	return s.indexes[IndexDefinition{Path: trimRoot(path), Field: field}.name()]
+}
+
+//update reindexes the children of the indexed nodes that the events are about,
+//every write emits an event for each node it changes, so these are all the changed children
+func (s *indexSet) update(t *tree, events []EventData) {
+	for _, idx := range s.indexes {
+		var dirty map[string]bool
+		for _, ev := range events {
+			if len(ev.Path) > len(idx.definition.Path) && hasPathPrefix(ev.Path, idx.definition.Path) {
+				if dirty == nil {
+					dirty = make(map[string]bool)
+				}
+
+				dirty[ev.Path[len(idx.definition.Path)]] = true
+			}
+		}
+
+		if dirty == nil {
+			continue
+		}
+
+		parent := t.findNode(idx.definition.Path, false)
+		for key := range dirty {
+			idx.refresh(t, parent, key)
+		}
+	}
+}
+
+func (s *indexSet) create(t *tree, definition IndexDefinition) error {
+	name := definition.name()
+	if _, ok := s.indexes[name]; ok {
+		return ErrIndexExists
+	}
+
+	idx := &index{
+		definition: definition,
+		values:     make(map[string]interface{}),
+	}
+
+	if parent := t.findNode(definition.Path, false); parent != nil {
+		for _, key := range parent.ChildKeys() {
+			idx.refresh(t, parent, key)
+		}
+	}
+
+	s.indexes[name] = idx
+	return nil
+}
+
+func (s *indexSet) drop(definition IndexDefinition) error {
+	name := definition.name()
+	if _, ok := s.indexes[name]; !ok {
+		return ErrIndexNotFound
+	}
+
+	delete(s.indexes, name)
+	return nil
+}
+
+func (s *indexSet) definitions() []IndexDefinition {
+	res := make([]IndexDefinition, 0, len(s.indexes))
+	for _, idx := range s.indexes {
+		res = append(res, idx.definition)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].name() < res[j].name()
+	})
+
+	return res
+}
+
+//CreateIndex indexes the children of the node at path by the value of their descendant at field.
+//Queries ordered by that child use the index instead of walking all children.
+func (db LiquidDb) CreateIndex(path Path, field ...string) error {
+	db.treeMutex.Lock()
+	defer db.treeMutex.Unlock()
+
+	return db.tree.indexes.create(db.tree, IndexDefinition{Path: trimRoot(path), Field: field})
+}
+
+//DropIndex removes the index created by CreateIndex with the same path and field
+func (db LiquidDb) DropIndex(path Path, field ...string) error {
+	db.treeMutex.Lock()
+	defer db.treeMutex.Unlock()
+
+	return db.tree.indexes.drop(IndexDefinition{Path: trimRoot(path), Field: field})
+}
+
+//Indexes returns the definitions of all indexes
+func (db LiquidDb) Indexes() []IndexDefinition {
+	db.treeMutex.RLock()
+	defer db.treeMutex.RUnlock()
+
+	return db.tree.indexes.definitions()
+}
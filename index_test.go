@@ -0,0 +1,126 @@
+package liquiddb
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func indexedUsers(t *testing.T) *LiquidDb {
+	t.Helper()
+
+	db := New()
+	users := map[string]interface{}{}
+	for i := 0; i < 50; i++ {
+		users[fmt.Sprintf("u%d", i)] = map[string]interface{}{
+			"email": fmt.Sprintf("user%02d@example.com", i%25),
+			"age":   i,
+		}
+	}
+	db.SetPath([]string{"users"}, users)
+
+	if err := db.CreateIndex([]string{"users"}, "email"); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+//queryWithoutIndex runs the query on a tree without indexes to compare the results
+func queryWithoutIndex(db *LiquidDb, path []string, query Query) []EventData {
+	db.treeMutex.RLock()
+	defer db.treeMutex.RUnlock()
+
+	unindexed := *db.tree
+	unindexed.indexes = newIndexSet()
+	res, _ := unindexed.Query(path, query)
+	return res
+}
+
+func TestIndex_Lookup(t *testing.T) {
+	db := indexedUsers(t)
+	users := []string{"users"}
+
+	queries := []Query{
+		{OrderBy: QueryOrderByChild, Child: []string{"email"}, EqualTo: "user03@example.com"},
+		{OrderBy: QueryOrderByChild, Child: []string{"email"}, StartAt: "user10", EndAt: "user12"},
+		{OrderBy: QueryOrderByChild, Child: []string{"email"}, LimitToLast: 3},
+	}
+
+	for _, query := range queries {
+		res, err := db.Query(users, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(res, queryWithoutIndex(db, users, query)) {
+			t.Fatalf("Index query %+v differs %+v", query, res)
+		}
+	}
+
+	res, _ := db.Query(users, queries[0])
+	if keys := queryKeys(res); !reflect.DeepEqual(keys, []string{"u28", "u3"}) {
+		t.Fatalf("Invalid equality lookup %+v", keys)
+	}
+}
+
+func TestIndex_Maintained(t *testing.T) {
+	db := indexedUsers(t)
+	users := []string{"users"}
+	byEmail := Query{OrderBy: QueryOrderByChild, Child: []string{"email"}, EqualTo: "new@example.com"}
+
+	db.SetPath([]string{"users", "u3", "email"}, "new@example.com")
+	db.Set(map[string]interface{}{
+		"users": map[string]interface{}{
+			"u100": map[string]interface{}{"email": "new@example.com"},
+		},
+	})
+	db.Delete([]string{"users", "u28"})
+	db.Transaction(func(tx *Tx) error {
+		tx.SetPath([]string{"users", "u4"}, map[string]interface{}{"email": "new@example.com"})
+		tx.Delete([]string{"users", "u100"})
+		return nil
+	})
+
+	res, _ := db.Query(users, byEmail)
+	if keys := queryKeys(res); !reflect.DeepEqual(keys, []string{"u3", "u4"}) {
+		t.Fatalf("Index not maintained %+v", keys)
+	}
+
+	all := Query{OrderBy: QueryOrderByChild, Child: []string{"email"}}
+	res, _ = db.Query(users, all)
+	if !reflect.DeepEqual(res, queryWithoutIndex(db, users, all)) {
+		t.Fatalf("Index out of sync")
+	}
+
+	db.Delete(users)
+	res, _ = db.Query(users, all)
+	if len(res) != 0 {
+		t.Fatalf("Index not emptied %+v", res)
+	}
+}
+
+func TestIndex_Definitions(t *testing.T) {
+	db := indexedUsers(t)
+
+	if err := db.CreateIndex([]string{"users"}, "email"); err != ErrIndexExists {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	db.CreateIndex([]string{"users"}, "age")
+	if defs := db.Indexes(); len(defs) != 2 || !reflect.DeepEqual(defs[1], IndexDefinition{[]string{"users"}, []string{"email"}}) {
+		t.Fatalf("Invalid definitions %+v", defs)
+	}
+
+	if err := db.DropIndex([]string{"users"}, "email"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.DropIndex([]string{"users"}, "email"); err != ErrIndexNotFound {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if defs := db.Indexes(); len(defs) != 1 {
+		t.Fatalf("Index not dropped %+v", defs)
+	}
+}
@@ -0,0 +1,144 @@
+package liquiddb
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-errors/errors"
+)
+
+//ErrClosed is returned by the writes to a closed database
+var ErrClosed = errors.New("Database closed")
+
+//lifecycle tracks whether the database is closed and the writes
+//which are committed but not yet published
+type lifecycle struct {
+	closed     uint32
+	publishing sync.WaitGroup
+
+	//published is closed once the last committed write is published, it is guarded by the writeMutex
+	published chan struct{}
+}
+
+//publishTurn orders the publishing of a committed write after the writes committed before it
+type publishTurn struct {
+	previous chan struct{}
+	done     chan struct{}
+}
+
+//committed registers a committed write to be published after the ones committed before it, so its events
+//are delivered in the order of their sequence numbers. The caller must hold the writeMutex and call finished
+//once the events are published.
+func (l *lifecycle) committed() publishTurn {
+	l.publishing.Add(1)
+	turn := publishTurn{previous: l.published, done: make(chan struct{})}
+	l.published = turn.done
+
+	return turn
+}
+
+//wait waits for the writes committed before to be published
+func (t publishTurn) wait() {
+	if t.previous != nil {
+		<-t.previous
+	}
+}
+
+//finished lets the next committed write be published
+func (l *lifecycle) finished(turn publishTurn) {
+	close(turn.done)
+	l.publishing.Done()
+}
+
+func (l *lifecycle) isClosed() bool {
+	return atomic.LoadUint32(&l.closed) == 1
+}
+
+//close marks the database as closed, the caller must hold the writeMutex.
+//It returns false when it was already closed.
+func (l *lifecycle) close() bool {
+	return atomic.CompareAndSwapUint32(&l.closed, 0, 1)
+}
+
+//writeLock serializes the writers like a mutex, unlike one waiting for it can be given up
+type writeLock chan struct{}
+
+func newWriteLock() writeLock {
+	return make(writeLock, 1)
+}
+
+func (l writeLock) Lock() {
+	l <- struct{}{}
+}
+
+func (l writeLock) Unlock() {
+	<-l
+}
+
+//LockContext waits for the lock until ctx is done, it returns the error of ctx when it gave up
+func (l writeLock) LockContext(ctx context.Context) error {
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//SetContext is Set giving up with the error of ctx when it is done before the write starts,
+//the wait for the other writers is the only part of a write which can be given up
+func (db LiquidDb) SetContext(ctx context.Context, data map[string]interface{}) ([]EventData, error) {
+	return db.mutateContext(ctx, logRecord{
+		Operation: logOperationSet,
+		Value:     data,
+	})
+}
+
+//SetPathContext is SetPath giving up with the error of ctx like SetContext
+func (db LiquidDb) SetPathContext(ctx context.Context, path Path, data interface{}) ([]EventData, error) {
+	return db.mutateContext(ctx, logRecord{
+		Operation: logOperationSetPath,
+		Path:      path,
+		Value:     data,
+	})
+}
+
+//DeleteContext is Delete giving up with the error of ctx like SetContext, a missing path is ErrNotFound
+func (db LiquidDb) DeleteContext(ctx context.Context, path Path) ([]EventData, error) {
+	return db.mutateContext(ctx, logRecord{
+		Operation: logOperationDelete,
+		Path:      path,
+	})
+}
+
+//GetContext is Get giving up with the error of ctx when it is done before the value is read,
+//the value is read from the last published root and given up while it is built
+func (db LiquidDb) GetContext(ctx context.Context, path Path) (EventData, error) {
+	if err := ctx.Err(); err != nil {
+		return EventData{}, err
+	}
+
+	op, err := db.ReadView().get(ctx, path)
+	db.touchRead(op)
+	if err != nil {
+		return EventData{}, err
+	}
+
+	evData := db.linker.link(db.linkID, op)
+	db.notifier.notifyInternal(evData...)
+	return evData[0], nil
+}
+
+//mutateContext commits record unless ctx is done before the writeMutex is acquired,
+//a write which has started is never given up half applied
+func (db LiquidDb) mutateContext(ctx context.Context, record logRecord) ([]EventData, error) {
+	return db.mutateWith(ctx, func() (logRecord, error) {
+		//the wait can end with both the lock acquired and ctx done
+		if err := ctx.Err(); err != nil {
+			return logRecord{}, err
+		}
+
+		return record, nil
+	})
+}
@@ -0,0 +1,155 @@
+package liquiddb
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLifecycle_Close(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	db := New()
+	ch := make(chan EventData)
+	db.NotifyPathWithOptions(ch, Path{}, SubscriberOptions{QueueSize: 100}, EventOperationInsert)
+	for i := 0; i < 20; i++ {
+		db.SetPath(Path{strconv.Itoa(i)}, i)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//every pending event is delivered before the channel is closed
+	received := 0
+	for range ch {
+		received++
+	}
+
+	if received != 20 {
+		t.Fatalf("Received %d events", received)
+	}
+
+	if _, err := db.SetPath(p, 1); err != ErrClosed {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if _, err := db.Transaction(func(tx *Tx) error { return nil }); err != ErrClosed {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if err := db.Notify(make(chan EventData), EventOperationInsert); err != ErrClosed {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if err := db.Close(); err != ErrClosed {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if v, _ := db.Get(Path{"1"}); v.Value != 1 {
+		t.Fatalf("Invalid value %+v", v.Value)
+	}
+
+	//the goroutines of the other tests can still be finishing
+	for i := 0; runtime.NumGoroutine() > before; i++ {
+		if i == 100 {
+			t.Fatalf("Leaked %d goroutines", runtime.NumGoroutine()-before)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLifecycle_Context(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := db.SetPathContext(ctx, p, 1); err != context.Canceled {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if _, err := db.GetContext(ctx, p); err != context.Canceled {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	//a write given up while it waits for the other writers is not applied
+	ctx, cancel = context.WithCancel(context.Background())
+	started := make(chan struct{})
+	done := make(chan error)
+	db.Transaction(func(tx *Tx) error {
+		go func() {
+			close(started)
+			_, err := db.SetPathContext(ctx, p, 1)
+			done <- err
+		}()
+
+		<-started
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+		return nil
+	})
+
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if v, _ := db.Get(p); v.Version != 0 {
+		t.Fatalf("Canceled write was applied %+v", v)
+	}
+
+	if _, err := db.SetContext(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.DeleteContext(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLifecycle_ContextWait(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	//the write gives up while the transaction still holds the other writers back
+	ctx, cancel := context.WithCancel(context.Background())
+	db.Transaction(func(tx *Tx) error {
+		done := make(chan error)
+		go func() {
+			_, err := db.SetPathContext(ctx, p, 1)
+			done <- err
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+
+		select {
+		case err := <-done:
+			if err != context.Canceled {
+				t.Errorf("Invalid error %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("The write waited for the transaction after it was canceled")
+		}
+
+		return nil
+	})
+
+	if v, _ := db.Get(p); v.Version != 0 {
+		t.Fatalf("Canceled write was applied %+v", v)
+	}
+
+	db.SetPath(Path{"big"}, map[string]interface{}{"a": map[string]interface{}{"b": 1}})
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := db.ReadView().get(ctx, Path{"big"}); err != context.Canceled {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if _, err := db.tree.getMatches(ctx, Path{"big", "*"}); err != context.Canceled {
+		t.Fatalf("Invalid error %v", err)
+	}
+}
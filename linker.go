@@ -22,7 +22,17 @@ func (l *linker) save(id uint64) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	l.links[id] = true
+	if l.links != nil {
+		l.links[id] = true
+	}
+}
+
+//close releases the saved links, the links saved after it are not kept
+func (l *linker) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.links = nil
 }
 
 func (l *linker) link(id uint64, data ...EventData) []EventData {
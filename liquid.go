@@ -1,7 +1,11 @@
 package liquiddb
 
 import (
-	"strings"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sasha-s/go-deadlock"
 )
 
 //LiquidDb provides the means to store data and be notified of changes
@@ -15,15 +19,105 @@ type LiquidDb struct {
 	tree   *tree
 	linker *linker
 	*notifier
+
+	//writes are serialized so that the log and the tree see them in the same order,
+	//treeMutex is held exclusively only while a write is applied so that the readers of the tree
+	//never observe half applied writes, Get reads the published root without it
+	writeMutex writeLock
+	treeMutex  *deadlock.RWMutex
+	log        *writeAheadLog
+	reaper     *reaper
+	quota      *quota
+	lifecycle  *lifecycle
+	changes    *changeLog
 }
 
 //New creates new database instance
 func New() *LiquidDb {
+	db := newLiquidDb()
+	db.startReaper(NewConfigBuilder().Finalize().ReapInterval)
+	return db
+}
+
+//newLiquidDb creates a database without the reaper, it is started
+//once the data is loaded so it never races with the loading
+func newLiquidDb() *LiquidDb {
+	changes := newChangeLog(NewConfigBuilder().Finalize().ChangeRetention)
 	return &LiquidDb{
-		tree:     newTree(),
-		linker:   newLinker(),
-		notifier: newNotifier(),
+		tree:       newTree(),
+		linker:     newLinker(),
+		notifier:   newNotifier(changes),
+		changes:    changes,
+		writeMutex: newWriteLock(),
+		treeMutex:  &deadlock.RWMutex{},
+		lifecycle:  &lifecycle{},
+	}
+}
+
+//NewWithConfig creates new database instance, when persistence is enabled
+//the write-ahead log is replayed to rebuild the data
+func NewWithConfig(config Config) (*LiquidDb, error) {
+	config = config.withDefaults()
+	db := newLiquidDb()
+	db.quota = newQuota(config)
+	db.changes.retention = config.ChangeRetention
+	if config.LogPath == "" {
+		db.startReaper(config.ReapInterval)
+		return db, nil
+	}
+
+	log, err := openWriteAheadLog(config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = log.replay(func(record logRecord) error {
+		op, err := record.apply(db.tree)
+		if err == ErrNotFound {
+			//older logs hold the deletes of missing paths
+			return nil
+		}
+
+		if err == nil {
+			//the sequence numbers continue where they were and the last changes can be resumed
+			db.changes.record(op, 0)
+		}
+
+		return err
+	})
+	if err != nil {
+		log.close()
+		return nil, err
 	}
+
+	db.tree.publish()
+	db.log = log
+	db.startReaper(config.ReapInterval)
+	return db, nil
+}
+
+//Close rejects the further writes with ErrClosed and waits for the ones in progress. The pending
+//notifications are delivered before the channels of the subscribers are closed, then the reaper
+//is stopped and the write-ahead log is flushed and closed. The data can still be read.
+//A subscriber with the OverflowBlock policy which stopped reading its channel makes Close wait
+//until it reads again or it is removed with StopNotify.
+func (db LiquidDb) Close() error {
+	db.writeMutex.Lock()
+	closed := db.lifecycle.close()
+	db.writeMutex.Unlock()
+	if !closed {
+		return ErrClosed
+	}
+
+	db.lifecycle.publishing.Wait()
+	db.reaper.close()
+	db.notifier.close()
+	db.linker.close()
+	if db.log == nil {
+		return nil
+	}
+
+	return db.log.close()
 }
 
 //Link links the EventData from the next call to the specified id
@@ -33,35 +127,207 @@ func (db LiquidDb) Link(id uint64) LiquidDb {
 	return db
 }
 
-//Set inserts a json in the database
-func (db LiquidDb) Set(data map[string]interface{}) ([]EventData, error) {
-	op, err := db.tree.Set(data)
+//VersionConflictError is returned by the conditional writes when the version
+//of the node has moved since it was read
+type VersionConflictError struct {
+	Path     Path
+	Expected uint64
+	Actual   uint64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("Version conflict at %s, expected %d, actual %d",
+		e.Path.String(), e.Expected, e.Actual)
+}
+
+//commit writes the record to the log, applies it to the tree and notifies about the changes
+func (db LiquidDb) commit(record logRecord) ([]EventData, error) {
+	return db.mutate(func() (logRecord, error) {
+		return record, nil
+	})
+}
+
+//mutate commits the record returned by build, build is called holding the
+//writeMutex so the record can safely depend on the current data
+func (db LiquidDb) mutate(build func() (logRecord, error)) ([]EventData, error) {
+	return db.mutateWith(context.Background(), build)
+}
+
+//mutateWith is mutate giving up with the error of ctx when it is done before the writeMutex is acquired
+func (db LiquidDb) mutateWith(ctx context.Context, build func() (logRecord, error)) ([]EventData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := db.writeMutex.LockContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if db.lifecycle.isClosed() {
+		db.writeMutex.Unlock()
+		return nil, ErrClosed
+	}
+
+	record, err := build()
 	if err != nil {
+		db.writeMutex.Unlock()
 		return nil, err
 	}
 
-	evData := db.linker.link(db.linkID, op...)
-	db.notifier.notifyInternal(evData...)
-	return op, nil
+	op, err := db.apply(record)
+	if err != nil {
+		db.writeMutex.Unlock()
+		return nil, err
+	}
+
+	//Close waits for the committed writes to be published
+	turn := db.lifecycle.committed()
+	db.writeMutex.Unlock()
+
+	return db.publishInTurn(op, turn), nil
 }
 
-//SetPath sets value by a path, the data can be another json for nested insertion
-func (db LiquidDb) SetPath(path []string, data interface{}) ([]EventData, error) {
-	//TODO: test
-	op, err := db.tree.SetPath(path, data)
+//apply writes the record to the log and applies it to the tree, the caller must hold the writeMutex
+func (db LiquidDb) apply(record logRecord) ([]EventData, error) {
+	//invalid records must never reach the log since they would fail its replay
+	if err := record.validate(); err != nil {
+		return nil, err
+	}
+
+	//neither must the records which fail when they are applied, the replay would fail with them
+	if err := record.check(db.tree); err != nil {
+		return nil, err
+	}
+
+	if err := db.checkSchemas(record); err != nil {
+		return nil, err
+	}
+
+	victims, err := db.checkQuota(record)
+	if err != nil {
+		return nil, err
+	}
+
+	op, err := db.write(record)
 	if err != nil {
 		return nil, err
 	}
 
+	//the victims are evicted only once the write they make room for succeeded, a failed eviction
+	//leaves the write over the limit until the next write that exceeds it evicts again
+	evicted, _ := db.evict(victims)
+
+	//the evictions are published together with the write they made room for
+	return append(op, evicted...), nil
+}
+
+//write appends the record to the log and applies it to the tree, the caller must hold the writeMutex
+func (db LiquidDb) write(record logRecord) ([]EventData, error) {
+	if db.log != nil {
+		if err := db.log.append(record); err != nil {
+			return nil, err
+		}
+	}
+
+	db.treeMutex.Lock()
+	defer db.treeMutex.Unlock()
+
+	op, err := record.apply(db.tree)
+	if err != nil {
+		//the record was checked before, a failing one must still neither stay in the log nor be partly applied
+		db.tree.rollback()
+		if db.log != nil {
+			db.log.discard()
+		}
+
+		return nil, err
+	}
+
+	db.tree.indexes.update(db.tree, op)
+	db.tree.histories.record(db.tree, op, db.linkID, time.Now().UTC())
+	db.tree.touchEvents(op)
+	db.changes.record(op, db.linkID)
+	db.tree.publish()
+
+	return op, nil
+}
+
+//publishInTurn publishes the events of a committed write once the writes committed before it are published
+func (db LiquidDb) publishInTurn(op []EventData, turn publishTurn) []EventData {
+	turn.wait()
+	defer db.lifecycle.finished(turn)
+
+	return db.publish(op)
+}
+
+func (db LiquidDb) publish(op []EventData) []EventData {
 	evData := db.linker.link(db.linkID, op...)
 	db.notifier.notifyInternal(evData...)
-	return evData, nil
+	return evData
+}
+
+//Set inserts a json in the database
+func (db LiquidDb) Set(data map[string]interface{}) ([]EventData, error) {
+	return db.commit(logRecord{
+		Operation: logOperationSet,
+		Value:     data,
+	})
+}
+
+//SetPath sets value by a path, the data can be another json for nested insertion
+func (db LiquidDb) SetPath(path Path, data interface{}) ([]EventData, error) {
+	return db.commit(logRecord{
+		Operation: logOperationSetPath,
+		Path:      path,
+		Value:     data,
+	})
+}
+
+//checkVersion returns a VersionConflictError if the version of the node at path
+//is not the expected one, a missing node has version 0
+func (db LiquidDb) checkVersion(path Path, expectedVersion uint64) error {
+	db.treeMutex.RLock()
+	defer db.treeMutex.RUnlock()
+
+	var version uint64
+	if node := db.tree.findNode(path, false); node != nil {
+		version = node.GetVersion()
+	}
+
+	if version != expectedVersion {
+		return &VersionConflictError{
+			Path:     path,
+			Expected: expectedVersion,
+			Actual:   version,
+		}
+	}
+
+	return nil
+}
+
+//SetPathIf sets value by a path only if the version of the node is expectedVersion,
+//an expectedVersion of 0 means that the path must not exist
+func (db LiquidDb) SetPathIf(path Path, data interface{}, expectedVersion uint64) ([]EventData, error) {
+	return db.mutate(func() (logRecord, error) {
+		if err := db.checkVersion(path, expectedVersion); err != nil {
+			return logRecord{}, err
+		}
+
+		return logRecord{
+			Operation: logOperationSetPath,
+			Path:      path,
+			Value:     data,
+		}, nil
+	})
 }
 
 //Get gets a value out of the store by a path formed by an array of strings
-func (db LiquidDb) Get(path []string) (EventData, error) {
+func (db LiquidDb) Get(path Path) (EventData, error) {
 	//TODO: return json if the tree continues to stem
-	op, err := db.tree.Get(path)
+	//the data is read from the last published root without waiting for the writers
+	op, err := db.ReadView().Get(path)
+	db.touchRead(op)
+
 	evData := db.linker.link(db.linkID, op)
 	//TODO: Do we want to notify on every get?
 	db.notifier.notifyInternal(evData...)
@@ -70,26 +336,57 @@ func (db LiquidDb) Get(path []string) (EventData, error) {
 	return evData[0], err
 }
 
-//GetByString gets a value out of the store by a path formed by a string with dots
+//GetByString gets a value out of the store by the string form of a path, see Path
 func (db LiquidDb) GetByString(path string) (interface{}, error) {
-	return db.tree.Get(strings.Split(path, "."))
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.ReadView().Get(p)
 }
 
 //Delete deletes a value from the store by a path
-func (db LiquidDb) Delete(path []string) ([]EventData, bool) {
+func (db LiquidDb) Delete(path Path) ([]EventData, bool) {
 	//TODO: should this return error too, just like Get, or should get not return error?
 	//the api must be consistent
-	op, ok := db.tree.Delete(path)
-	if !ok {
+	op, err := db.TryDelete(path)
+	if err != nil {
 		return nil, false
 	}
 
-	evData := db.linker.link(db.linkID, op...)
-	db.notifier.notifyInternal(evData...)
 	return op, true
 }
 
-//DeleteByString deletes a value from the store by a path formed as string separated by dots
+//TryDelete deletes a value from the store by a path like Delete, it returns why nothing was deleted,
+//ErrNotFound for a missing path or the error of a schema rejecting the delete
+func (db LiquidDb) TryDelete(path Path) ([]EventData, error) {
+	return db.commit(logRecord{
+		Operation: logOperationDelete,
+		Path:      path,
+	})
+}
+
+//DeleteIf deletes a value from the store by a path only if the version of the node is expectedVersion
+func (db LiquidDb) DeleteIf(path Path, expectedVersion uint64) ([]EventData, error) {
+	return db.mutate(func() (logRecord, error) {
+		if err := db.checkVersion(path, expectedVersion); err != nil {
+			return logRecord{}, err
+		}
+
+		return logRecord{
+			Operation: logOperationDelete,
+			Path:      path,
+		}, nil
+	})
+}
+
+//DeleteByString deletes a value from the store by the string form of a path, see Path
 func (db LiquidDb) DeleteByString(path string) ([]EventData, bool) {
-	return db.Delete(strings.Split(path, "."))
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, false
+	}
+
+	return db.Delete(p)
 }
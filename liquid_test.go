@@ -10,7 +10,7 @@ import (
 
 var (
 	b    = []byte("foobar")
-	p    = []string{"foo", "bar"}
+	p    = Path{"foo", "bar"}
 	data = map[string]interface{}{
 		"foo": map[string]interface{}{
 			"bar": b,
@@ -42,13 +42,14 @@ func TestNotify(t *testing.T) {
 									t.Errorf("Invalid value, %s", notf.Value)
 								}
 
-								if !reflect.DeepEqual(notf.Path, []string{"foo", "bar"}) {
+								if !reflect.DeepEqual(notf.Path, Path{"foo", "bar"}) {
 									t.Errorf("Invalid path, %s", notf.Path)
 								}
 
 								valid = true
 							}
-						default:
+						//the events are delivered asynchronously, wait a bit for the next one
+						case <-time.After(50 * time.Millisecond):
 							if !valid {
 								t.Error("Invalid notification")
 							}
@@ -125,3 +126,66 @@ func TestStopNotify(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestVersion_Increases(t *testing.T) {
+	store := New()
+
+	first, _ := store.Set(data)
+	second, _ := store.SetPath(p, "changed")
+
+	if first[1].Version == 0 || second[0].Version <= first[1].Version {
+		t.Fatalf("Versions not increasing %d %d", first[1].Version, second[0].Version)
+	}
+
+	parent, _ := store.Get([]string{"foo"})
+	if parent.Version != second[0].Version {
+		t.Fatalf("Parent version %d not moved to %d", parent.Version, second[0].Version)
+	}
+}
+
+func TestSetPathIf(t *testing.T) {
+	store := New()
+
+	if _, err := store.SetPathIf(p, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := store.SetPathIf(p, 2, 0)
+	conflict, ok := err.(*VersionConflictError)
+	if !ok {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	current, _ := store.Get(p)
+	if conflict.Actual != current.Version {
+		t.Fatalf("Invalid conflict version %d, expected %d", conflict.Actual, current.Version)
+	}
+
+	ops, err := store.SetPathIf(p, 2, current.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ops[0].Value != 2 || ops[0].Version <= current.Version {
+		t.Fatalf("Invalid op %+v", ops[0])
+	}
+}
+
+func TestDeleteIf(t *testing.T) {
+	store := New()
+	store.Set(data)
+
+	current, _ := store.Get(p)
+	if _, err := store.DeleteIf(p, current.Version+1); err == nil {
+		t.Fatal("Deleted with a wrong version")
+	}
+
+	if _, err := store.DeleteIf(p, current.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	v, _ := store.Get(p)
+	if v.Value != nil {
+		t.Fatalf("Value not deleted %+v", v.Value)
+	}
+}
@@ -0,0 +1,515 @@
+package liquiddb
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/sasha-s/go-deadlock"
+)
+
+const (
+	//nodeOverhead approximates the bytes a node uses besides its key and value, its entry in the children of its parent included.
+	//The older versions of the nodes only kept alive by a ReadView are not counted.
+	nodeOverhead = 256
+)
+
+//nodeSize approximates the bytes a node with key and without a value uses
+func nodeSize(key string) int64 {
+	return nodeOverhead + int64(len(key))
+}
+
+//QuotaError is returned when a write would make the tree or the subtree of a top level key
+//use more memory than its limit, Key is empty for the limit of the whole tree
+type QuotaError struct {
+	Key   string
+	Limit int64
+	Size  int64
+}
+
+func (e *QuotaError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("Memory limit of %d bytes exceeded, the write would use %d bytes", e.Limit, e.Size)
+	}
+
+	return fmt.Sprintf("Memory limit of %d bytes exceeded for %s, the write would use %d bytes", e.Limit, e.Key, e.Size)
+}
+
+//valueSize approximates the bytes used by a value of a node
+func valueSize(v interface{}) int64 {
+	switch value := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(value))
+	case []byte:
+		return int64(len(value))
+	case bool, int8, uint8:
+		return 1
+	case int16, uint16:
+		return 2
+	case int32, uint32, float32:
+		return 4
+	default:
+		return 8
+	}
+}
+
+//touch marks the node at path and its ancestors as accessed now
+func (t *tree) touch(path Path) {
+	t.touchAt(path, atomic.AddUint64(t.clock, 1))
+}
+
+//touchAt marks the node at path and its ancestors as accessed at tick, unless they were accessed later
+func (t *tree) touchAt(path Path, tick uint64) {
+	for _, node := range t.mutablePath(path, false) {
+		if node.accessed < tick {
+			node.accessed = tick
+		}
+	}
+}
+
+//touchEvents marks the nodes written by the events as accessed
+func (t *tree) touchEvents(events []EventData) {
+	for _, ev := range events {
+		if ev.Operation == EventOperationDelete {
+			continue
+		}
+
+		t.touch(ev.Path)
+	}
+}
+
+//touch records a read of path when recency is needed by the eviction, it never waits for the writers,
+//the next write applies the recorded reads to the tree before it chooses what to evict
+func (db LiquidDb) touch(path Path) {
+	if db.quota == nil || db.quota.policy != EvictionLRU {
+		return
+	}
+
+	db.quota.recordRead(path, atomic.AddUint64(db.tree.clock, 1))
+}
+
+//touchRead records the reads of the event of a get, every match of a pattern is read
+func (db LiquidDb) touchRead(ev EventData) {
+	if ev.Pattern == nil {
+		db.touch(ev.Path)
+		return
+	}
+
+	for _, m := range ev.Matches {
+		db.touch(m.Path)
+	}
+}
+
+//cacheRead is the last read of a child of a cache path
+type cacheRead struct {
+	path Path
+	tick uint64
+}
+
+//quota holds the memory limits of a database
+type quota struct {
+	limit      int64
+	keyLimits  map[string]int64
+	policy     EvictionPolicy
+	cachePaths []Path
+
+	//reads holds the reads of the children of the cache paths since the last write, keyed by their paths
+	readsMutex *deadlock.Mutex
+	reads      map[string]cacheRead
+}
+
+//newQuota creates the quota of config, nil when it has no limits
+func newQuota(config Config) *quota {
+	if config.MemoryLimit <= 0 && len(config.KeyMemoryLimits) == 0 {
+		return nil
+	}
+
+	return &quota{
+		limit:      config.MemoryLimit,
+		keyLimits:  config.KeyMemoryLimits,
+		policy:     config.EvictionPolicy,
+		cachePaths: config.CachePaths,
+		readsMutex: &deadlock.Mutex{},
+		reads:      make(map[string]cacheRead),
+	}
+}
+
+//recordRead keeps the tick of a read of path for the child of a cache path containing it,
+//only those children are evicted so the other reads are not kept
+func (q *quota) recordRead(path Path, tick uint64) {
+	path = path.Relative()
+	for _, cache := range q.cachePaths {
+		cache = cache.Relative()
+		if len(path) <= len(cache) || !hasPathPrefix(path, cache) {
+			continue
+		}
+
+		entry := path[:len(cache)+1]
+		q.readsMutex.Lock()
+		q.reads[entry.String()] = cacheRead{path: entry, tick: tick}
+		q.readsMutex.Unlock()
+		return
+	}
+}
+
+//applyReads marks the children of the cache paths read since the last write as accessed when they were read,
+//the caller must hold the writeMutex and the lock of the tree
+func (q *quota) applyReads(t *tree) {
+	q.readsMutex.Lock()
+	reads := q.reads
+	q.reads = make(map[string]cacheRead)
+	q.readsMutex.Unlock()
+
+	for _, read := range reads {
+		t.touchAt(read.path, read.tick)
+	}
+}
+
+//sizes returns the size of the whole tree under the empty key and the sizes of the top level keys
+func sizes(t *tree) map[string]int64 {
+	res := map[string]int64{"": t.root.GetSize()}
+	for _, child := range t.root.childNodes() {
+		res[child.Key] = child.GetSize()
+	}
+
+	return res
+}
+
+//growth holds the bytes a write adds to the whole tree under the empty key and to the subtrees of the top level keys
+type growth map[string]int64
+
+//add adds the bytes a write adds at path, a path relative to the root
+func (g growth) add(path Path, delta int64) {
+	g[""] += delta
+	if len(path) > 0 {
+		g[path[0]] += delta
+	}
+}
+
+//subtreeSize approximates the bytes a node with key and value would use with its descendants
+func subtreeSize(key string, value interface{}) int64 {
+	size := nodeSize(key)
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for childKey, child := range v {
+			size += subtreeSize(childKey, child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			size += subtreeSize(strconv.Itoa(i), child)
+		}
+	default:
+		size += valueSize(value)
+	}
+
+	return size
+}
+
+//writeGrowth adds the bytes replacing the node at path with value adds, counting the ancestors it creates
+func (t *tree) writeGrowth(grown growth, path Path, value interface{}) {
+	key := TreeRoot
+	if len(path) > 0 {
+		key = path[len(path)-1]
+	}
+
+	delta := subtreeSize(key, value)
+	if node := t.findNode(path, false); node != nil {
+		delta -= node.GetSize()
+	} else {
+		for i := len(path) - 1; i > 0 && t.findNode(path[:i], false) == nil; i-- {
+			delta += nodeSize(path[i-1])
+		}
+	}
+
+	grown.add(path, delta)
+}
+
+//updateGrowth adds the bytes merging value into the node at path adds, like tree.update merges it
+func (t *tree) updateGrowth(grown growth, path Path, value interface{}) {
+	partial, isMap := value.(map[string]interface{})
+	node := t.findNode(path, false)
+
+	switch {
+	case isMap && node != nil && isObject(node):
+		for key, child := range partial {
+			t.updateGrowth(grown, appendPath(path, key), child)
+		}
+	case value == nil:
+		if node != nil {
+			grown.add(path, -node.GetSize())
+		}
+	default:
+		t.writeGrowth(grown, path, value)
+	}
+}
+
+//setGrowth adds the bytes setting value at path adds, like tree.Set it merges objects and replaces arrays and values
+func (t *tree) setGrowth(grown growth, path Path, value interface{}) {
+	node := t.findNode(path, false)
+	object, isMap := value.(map[string]interface{})
+	_, isArray := value.([]interface{})
+
+	switch {
+	case node == nil || isArray || (isMap && node.IsArray()):
+		t.writeGrowth(grown, path, value)
+	case isMap:
+		for key, child := range object {
+			t.setGrowth(grown, appendPath(path, key), child)
+		}
+	default:
+		grown.add(path, valueSize(value)-valueSize(node.GetValue()))
+	}
+}
+
+//recordGrowth adds the bytes applying the record to the tree adds, from the sizes the nodes keep
+func (t *tree) recordGrowth(grown growth, record logRecord) {
+	path := record.Path.Relative()
+	node := t.findNode(path, false)
+
+	switch record.Operation {
+	case logOperationSet:
+		data, _ := record.Value.(map[string]interface{})
+		for key, value := range data {
+			t.setGrowth(grown, Path{key}, value)
+		}
+	case logOperationSetPath:
+		t.writeGrowth(grown, path, record.Value)
+	case logOperationUpdate:
+		t.updateGrowth(grown, path, record.Value)
+	case logOperationDelete:
+		if node != nil && !IsPattern(path) {
+			grown.add(path, -node.GetSize())
+		}
+	case logOperationIncrement, logOperationMin, logOperationMax, logOperationAppend:
+		switch {
+		case node == nil:
+			t.writeGrowth(grown, path, record.Value)
+		case t.isArrayAppend(record.Operation, node, record.Value):
+			length := node.childCount()
+			for i, item := range appendItems(record.Value) {
+				grown.add(path, subtreeSize(strconv.Itoa(length+i), item))
+			}
+		case record.Operation == logOperationAppend:
+			grown.add(path, valueSize(record.Value))
+		default:
+			//the result is either the value or the operand, so it is at most as big as the bigger of them
+			if delta := valueSize(record.Value) - valueSize(node.GetValue()); delta > 0 {
+				grown.add(path, delta)
+			}
+		}
+	case logOperationSplice:
+		items, _ := record.Value.([]interface{})
+		if node == nil {
+			t.writeGrowth(grown, path, items)
+			return
+		}
+
+		for i, item := range items {
+			grown.add(path, subtreeSize(strconv.Itoa(record.Index+i), item))
+		}
+
+		elements := t.arrayElements(node)
+		for i := record.Index; i < record.Index+record.Count && i < len(elements); i++ {
+			grown.add(path, -elements[i].GetSize())
+		}
+	case logOperationBatch:
+		for _, r := range record.Records {
+			t.recordGrowth(grown, r)
+		}
+	}
+}
+
+//disjointRecords returns whether none of the records changes the data another one changes
+func disjointRecords(records []logRecord) bool {
+	for i := range records {
+		for j := i + 1; j < len(records); j++ {
+			a, b := recordScope(records[i]), recordScope(records[j])
+			if hasPathPrefix(a, b) || hasPathPrefix(b, a) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+//exceeded returns the limits a write exceeds, keyed like sizes. A write that does not grow a subtree never exceeds its limit.
+func (q *quota) exceeded(t *tree, grown growth) map[string]*QuotaError {
+	res := make(map[string]*QuotaError)
+	check := func(key string, current, limit int64) {
+		delta := grown[key]
+		if limit > 0 && delta > 0 && current+delta > limit {
+			res[key] = &QuotaError{Key: key, Limit: limit, Size: current + delta}
+		}
+	}
+
+	check("", t.root.GetSize(), q.limit)
+	for key, limit := range q.keyLimits {
+		var current int64
+		if child, ok := t.root.child(key); ok {
+			current = child.GetSize()
+		}
+
+		check(key, current, limit)
+	}
+
+	return res
+}
+
+//victims returns the least recently accessed children of the cache paths whose eviction brings
+//the exceeded limits back under them, the ones the write changes are never evicted
+func (q *quota) victims(t *tree, scope Path, exceeded map[string]*QuotaError) ([]Path, error) {
+	needs := make(map[string]int64)
+	for key, err := range exceeded {
+		needs[key] = err.Size - err.Limit
+	}
+
+	candidates := make([]*Node, 0)
+	for _, path := range q.cachePaths {
+		cache := t.findNode(path, false)
+		if cache == nil {
+			continue
+		}
+
+		for _, child := range cache.childNodes() {
+			if !hasPathPrefix(scope, child.Path) && !hasPathPrefix(child.Path, scope) {
+				candidates = append(candidates, child)
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].GetAccessed() < candidates[j].GetAccessed()
+	})
+
+	victims := make([]Path, 0)
+	for _, candidate := range candidates {
+		key := candidate.Path[0]
+		if needs[""] <= 0 && needs[key] <= 0 {
+			continue
+		}
+
+		size := candidate.GetSize()
+		needs[""] -= size
+		needs[key] -= size
+		victims = append(victims, candidate.Path)
+	}
+
+	for _, key := range sortedKeys(exceeded) {
+		if needs[key] > 0 {
+			return nil, exceeded[key]
+		}
+	}
+
+	return victims, nil
+}
+
+//sortedKeys returns the keys of the exceeded limits, the limit of the whole tree first
+func sortedKeys(exceeded map[string]*QuotaError) []string {
+	keys := make([]string, 0, len(exceeded))
+	for key := range exceeded {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+//checkQuota estimates how much a record grows the tree from the sizes the nodes keep and returns the paths
+//that have to be evicted after it is applied, the caller must hold the writeMutex so the data cannot change
+//before the record is applied
+func (db LiquidDb) checkQuota(record logRecord) ([]Path, error) {
+	//expiring and evicting data only frees memory
+	if db.quota == nil || record.Reason != "" {
+		return nil, nil
+	}
+
+	scope := recordScope(record)
+	grown, err := db.growth(record)
+	if err != nil {
+		return nil, err
+	}
+
+	db.treeMutex.Lock()
+	defer db.treeMutex.Unlock()
+
+	exceeded := db.quota.exceeded(db.tree, grown)
+	if len(exceeded) == 0 {
+		return nil, nil
+	}
+
+	if db.quota.policy != EvictionLRU {
+		return nil, exceeded[sortedKeys(exceeded)[0]]
+	}
+
+	//the reads copy the nodes they mark like a write does, they are published right away
+	//so a write that fails and is rolled back does not drop them
+	db.quota.applyReads(db.tree)
+	db.tree.publish()
+	return db.quota.victims(db.tree, scope, exceeded)
+}
+
+//growth returns how much a record grows the tree, batches whose records change the same data are applied
+//to a copy of it since the sizes the nodes keep do not include the records before
+func (db LiquidDb) growth(record logRecord) (growth, error) {
+	if record.Operation == logOperationBatch && !disjointRecords(record.Records) {
+		view := db.view(recordScope(record))
+		before := sizes(view)
+		if _, err := record.apply(view); err != nil && err != ErrNotFound {
+			return nil, err
+		}
+
+		grown := growth{}
+		for key, size := range before {
+			grown[key] -= size
+		}
+		for key, size := range sizes(view) {
+			grown[key] += size
+		}
+
+		return grown, nil
+	}
+
+	db.treeMutex.RLock()
+	defer db.treeMutex.RUnlock()
+
+	grown := growth{}
+	db.tree.recordGrowth(grown, record)
+	return grown, nil
+}
+
+//evict deletes the paths to make room for a write and returns the delete events,
+//the caller must hold the writeMutex
+func (db LiquidDb) evict(paths []Path) ([]EventData, error) {
+	ops := make([]EventData, 0)
+	for _, path := range paths {
+		op, err := db.apply(logRecord{
+			Operation: logOperationDelete,
+			Path:      path,
+			Reason:    EventReasonEvicted,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		ops = append(ops, op...)
+	}
+
+	return ops, nil
+}
+
+//MemoryUsage returns the approximate amount of bytes used by the subtree at path
+func (db LiquidDb) MemoryUsage(path Path) (int64, error) {
+	db.treeMutex.RLock()
+	defer db.treeMutex.RUnlock()
+
+	node := db.tree.findNode(path, false)
+	if node == nil {
+		return 0, ErrNotFound
+	}
+
+	return node.GetSize(), nil
+}
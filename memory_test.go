@@ -0,0 +1,206 @@
+package liquiddb
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemory_Accounting(t *testing.T) {
+	db := New()
+	db.SetPath(Path{"a", "b"}, strings.Repeat("x", 1000))
+	db.SetPath(Path{"a", "c"}, []interface{}{"1", "2", "3"})
+
+	size, err := db.MemoryUsage(Path{"a", "b"})
+	if err != nil || size != nodeSize("b")+1000 {
+		t.Fatalf("Invalid size %d %v", size, err)
+	}
+
+	total, _ := db.MemoryUsage(Path{TreeRoot})
+	db.Splice(Path{"a", "c"}, 0, 1)
+	db.Increment(Path{"a", "d"}, 1)
+	db.Delete(Path{"a", "d"})
+	db.Splice(Path{"a", "c"}, 0, 0, "1")
+	if after, _ := db.MemoryUsage(Path{TreeRoot}); after != total {
+		t.Fatalf("Invalid size after the writes %d, expected %d", after, total)
+	}
+
+	db.Delete(Path{"a"})
+	if size, _ := db.MemoryUsage(Path{TreeRoot}); size != nodeSize(TreeRoot) {
+		t.Fatalf("Invalid size of the empty tree %d", size)
+	}
+}
+
+func TestMemory_Reject(t *testing.T) {
+	db, err := NewWithConfig(NewConfigBuilder().
+		MemoryLimit(10000).
+		KeyMemoryLimit("logs", 2000).
+		Finalize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.SetPath(Path{"logs", "l1"}, strings.Repeat("x", 1000)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.SetPath(Path{"logs", "l2"}, strings.Repeat("x", 1000))
+	if quotaErr, ok := err.(*QuotaError); !ok || quotaErr.Key != "logs" || quotaErr.Limit != 2000 {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	_, err = db.SetPath(Path{"big"}, strings.Repeat("x", 10000))
+	if quotaErr, ok := err.(*QuotaError); !ok || quotaErr.Key != "" {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	//writes that free memory are always allowed
+	if _, err := db.SetPath(Path{"logs", "l1"}, "x"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemory_Evict(t *testing.T) {
+	db, err := NewWithConfig(NewConfigBuilder().
+		KeyMemoryLimit("cache", 4500).
+		EvictionPolicy(EvictionLRU).
+		CachePath(Path{"cache"}).
+		Finalize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	value := strings.Repeat("x", 1000)
+	for _, key := range []string{"c1", "c2", "c3"} {
+		db.SetPath(Path{"cache", key}, value)
+	}
+	db.Get(Path{"cache", "c1"})
+
+	ops, err := db.SetPath(Path{"cache", "c4"}, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the write comes first, its evictions follow it
+	if len(ops) != 2 || ops[0].Path.String() != "cache.c4" || ops[1].Sequence <= ops[0].Sequence {
+		t.Fatalf("Invalid events %+v", ops)
+	}
+
+	if ops[1].Operation != EventOperationDelete || ops[1].Reason != EventReasonEvicted ||
+		ops[1].Path.String() != "cache.c2" {
+		t.Fatalf("Invalid eviction %+v", ops[1])
+	}
+
+	if _, err := db.MemoryUsage(Path{"cache", "c1"}); err != nil {
+		t.Fatal("Evicted a recently read subtree")
+	}
+
+	if _, err := db.SetPath(Path{"cache", "c5"}, strings.Repeat("x", 5000)); err == nil {
+		t.Fatal("Accepted a write that does not fit even after evicting")
+	}
+}
+
+func TestMemory_EvictFailedWrite(t *testing.T) {
+	db, err := NewWithConfig(NewConfigBuilder().
+		KeyMemoryLimit("cache", 4500).
+		EvictionPolicy(EvictionLRU).
+		CachePath(Path{"cache"}).
+		Finalize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	value := strings.Repeat("x", 1000)
+	db.SetPath(Path{"cache", "list"}, []interface{}{"x"})
+	db.SetPath(Path{"cache", "c1"}, value)
+	db.SetPath(Path{"cache", "c2"}, value)
+
+	if _, err := db.SetPath(Path{"cache", "list", "5"}, value); err != ErrIndexOutOfRange {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	for _, key := range []string{"c1", "c2"} {
+		if _, err := db.MemoryUsage(Path{"cache", key}); err != nil {
+			t.Fatalf("Evicted %s for a write that failed", key)
+		}
+	}
+}
+
+func TestMemory_Growth(t *testing.T) {
+	db := New()
+	db.SetPath(Path{"a", "list"}, []interface{}{"1", "2", "3"})
+	db.SetPath(Path{"a", "n"}, 1)
+
+	records := []logRecord{
+		{Operation: logOperationSetPath, Path: Path{"b", "c", "d"}, Value: map[string]interface{}{"e": "xyz", "f": []interface{}{1.0, "g"}}},
+		{Operation: logOperationSetPath, Path: Path{"a", "n"}, Value: "long value"},
+		{Operation: logOperationUpdate, Path: Path{"a"}, Value: map[string]interface{}{"n": nil, "m": map[string]interface{}{"o": true}}},
+		{Operation: logOperationSplice, Path: Path{"a", "list"}, Index: 1, Count: 1, Value: []interface{}{"x", "y"}},
+		{Operation: logOperationAppend, Path: Path{"a", "list"}, Value: []interface{}{"z"}},
+		{Operation: logOperationIncrement, Path: Path{"a", "count"}, Value: 1},
+		{Operation: logOperationDelete, Path: Path{"a", "list"}},
+		{Operation: logOperationSet, Value: map[string]interface{}{"b": map[string]interface{}{"c": map[string]interface{}{"q": 2.0}}}},
+		{Operation: logOperationBatch, Records: []logRecord{
+			{Operation: logOperationSetPath, Path: Path{"t", "u"}, Value: "x"},
+			{Operation: logOperationSetPath, Path: Path{"t", "u"}, Value: "yz"},
+		}},
+		{Operation: logOperationSet, Value: map[string]interface{}{"a": "x", "h": 1}},
+	}
+
+	for _, record := range records {
+		grown, err := db.growth(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		before, _ := db.MemoryUsage(Path{TreeRoot})
+		if _, err := db.commit(record); err != nil {
+			t.Fatal(err)
+		}
+
+		if after, _ := db.MemoryUsage(Path{TreeRoot}); after-before != grown[""] {
+			t.Fatalf("Invalid growth of %+v %d, expected %d", record, grown[""], after-before)
+		}
+	}
+}
+
+func TestMemory_ReadsWithoutTreeLock(t *testing.T) {
+	db, err := NewWithConfig(NewConfigBuilder().
+		KeyMemoryLimit("cache", 4500).
+		EvictionPolicy(EvictionLRU).
+		CachePath(Path{"cache"}).
+		Finalize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	value := strings.Repeat("x", 1000)
+	for _, key := range []string{"c1", "c2", "c3"} {
+		db.SetPath(Path{"cache", key}, value)
+	}
+
+	//the reads only record their accesses while a write holds the tree
+	db.treeMutex.Lock()
+	done := make(chan struct{})
+	go func() {
+		db.Get(Path{"cache", "c1"})
+		db.Get(Path{"cache", "c2"})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Get waited for the lock of the tree")
+	}
+	db.treeMutex.Unlock()
+
+	ops, err := db.SetPath(Path{"cache", "c4"}, value)
+	if err != nil || len(ops) != 2 || ops[1].Path.String() != "cache.c3" {
+		t.Fatalf("Invalid eviction %+v %v", ops, err)
+	}
+}
@@ -1,92 +1,160 @@
 package liquiddb
 
 import (
-	"github.com/sasha-s/go-deadlock"
-
-	"github.com/orcaman/concurrent-map"
+	"strconv"
+	"time"
 )
 
-//Node is a node in the tree
+//Node is a node in the tree. Nodes are immutable once the write that created them is published,
+//a write copies the nodes it changes together with their ancestors, so the published roots share
+//everything it did not change and they are read without locks.
 type Node struct {
-	Key      string
-	Path     []string
-	Children cmap.ConcurrentMap
+	Key  string
+	Path Path
+
+	value    interface{}
+	pristine bool
+	version  uint64
+
+	//expiry is when the node is deleted by the reaper, zero if it has no TTL
+	expiry time.Time
+
+	//the children of array nodes are keyed by their index
+	array bool
 
-	valueMutex deadlock.Mutex
-	value      interface{}
+	//children maps the keys of the children to them in compareKeys order
+	children *pmap
 
-	parentMutex deadlock.Mutex
-	parent      *Node
+	//size is the approximate amount of bytes used by the node and its descendants
+	size int64
 
-	pristineMutex deadlock.Mutex
-	pristine      bool
+	//accessed is the tick of the tree clock when the node or a descendant was last read or written
+	accessed uint64
+
+	//owner is the edit of the tree the node was created by, only that edit changes it in place
+	owner uint64
 }
 
-//newNode creates a new node in the tree
-func newNode(key string, parent *Node) *Node {
-	var parentPath []string
-	if parent == nil || parent.Key == TreeRoot {
-		parentPath = []string{}
-	} else {
-		parentPath = parent.Path
+//compareKeys orders keys the way they are returned by scans, keys that are integers
+//come first in numeric order, followed by the rest of the keys in lexicographic order
+func compareKeys(a, b string) int {
+	aInt, aErr := strconv.ParseInt(a, 10, 64)
+	bInt, bErr := strconv.ParseInt(b, 10, 64)
+
+	switch {
+	case aErr == nil && bErr == nil:
+		if aInt < bInt {
+			return -1
+		} else if aInt > bInt {
+			return 1
+		}
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
 	}
 
-	node := &Node{
-		Key:      key,
-		value:    nil,
-		parent:   parent,
-		Children: cmap.New(),
-		Path:     append(parentPath, key),
+	return 0
+}
+
+//childPath creates the path of a child with key, the path is always
+//a new slice so that siblings never share their backing arrays
+func childPath(parentPath Path, key string) Path {
+	parentPath = parentPath.Relative()
+
+	path := make(Path, len(parentPath)+1)
+	copy(path, parentPath)
+	path[len(parentPath)] = key
+
+	return path
+}
+
+//newNode creates a node with key below the node at parentPath, owned by the edit owner
+func newNode(key string, parentPath Path, owner uint64) *Node {
+	return &Node{
+		Key:   key,
+		Path:  childPath(parentPath, key),
+		owner: owner,
 
 		pristine: true,
+		size:     nodeSize(key),
 	}
+}
 
-	if parent != nil {
-		parent.Children.Set(node.Key, node)
-		parent.SetValue(nil)
-	}
+func (n *Node) GetValue() interface{} {
+	return n.value
+}
 
-	return node
+func (n *Node) GetPristine() bool {
+	return n.pristine
 }
 
-func (n *Node) GetValue() interface{} {
-	n.valueMutex.Lock()
-	defer n.valueMutex.Unlock()
+//GetVersion returns the revision of the last write that changed the node or its descendants
+func (n *Node) GetVersion() uint64 {
+	return n.version
+}
 
-	return n.value
+//GetExpiry returns when the node expires, the zero time if it has no TTL
+func (n *Node) GetExpiry() time.Time {
+	return n.expiry
 }
 
-func (n *Node) SetValue(v interface{}) {
-	n.valueMutex.Lock()
-	defer n.valueMutex.Unlock()
+//expiresAt returns when the node expires as event metadata, nil if it has no TTL
+func (n *Node) expiresAt() *time.Time {
+	if n.expiry.IsZero() {
+		return nil
+	}
 
-	n.value = v
+	expiry := n.expiry
+	return &expiry
 }
 
-func (n *Node) SetPristine(p bool) {
-	n.pristineMutex.Lock()
-	defer n.pristineMutex.Unlock()
+func (n *Node) IsArray() bool {
+	return n.array
+}
 
-	n.pristine = p
+//child returns the child with key
+func (n *Node) child(key string) (*Node, bool) {
+	return n.children.get(key)
 }
 
-func (n *Node) GetPristine() bool {
-	n.pristineMutex.Lock()
-	defer n.pristineMutex.Unlock()
+//childCount returns the amount of children
+func (n *Node) childCount() int {
+	return n.children.len()
+}
 
-	return n.pristine
+//ChildKeys returns the keys of the children ordered by compareKeys
+func (n *Node) ChildKeys() []string {
+	keys := make([]string, 0, n.children.len())
+	n.children.each(func(child *Node) {
+		keys = append(keys, child.Key)
+	})
+
+	return keys
 }
 
-func (n *Node) SetParent(newParent *Node) {
-	n.parentMutex.Lock()
-	defer n.parentMutex.Unlock()
+//childNodes returns the children ordered by their keys
+func (n *Node) childNodes() []*Node {
+	children := make([]*Node, 0, n.children.len())
+	n.children.each(func(child *Node) {
+		children = append(children, child)
+	})
 
-	n.parent = newParent
+	return children
 }
 
-func (n *Node) GetParent() *Node {
-	n.parentMutex.Lock()
-	defer n.parentMutex.Unlock()
+//GetSize returns the approximate amount of bytes used by the node and its descendants
+func (n *Node) GetSize() int64 {
+	return n.size
+}
 
-	return n.parent
+//GetAccessed returns the tick of the tree clock when the node or a descendant was last read or written
+func (n *Node) GetAccessed() uint64 {
+	return n.accessed
 }
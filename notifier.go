@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/go-errors/errors"
-	"github.com/thoas/go-funk"
 )
 
 //EventOperation is a db operation
@@ -21,15 +20,40 @@ const (
 	EventOperationUpdate = EventOperation("update")
 	//EventOperationGet is a get db operation
 	EventOperationGet = EventOperation("get")
+	//EventOperationChangeSet holds the changes of a mutation in Changes, see SubscriberOptions
+	EventOperationChangeSet = EventOperation("changeSet")
+)
+
+//EventReason tells why an event happened when it was not caused by a client call
+type EventReason string
+
+const (
+	//EventReasonExpired marks the deletes made by the reaper when a TTL runs out
+	EventReasonExpired = EventReason("expired")
+	//EventReasonEvicted marks the deletes made to keep the tree within its memory limits
+	EventReasonEvicted = EventReason("evicted")
 )
 
 //EventData is a whole db event holding data and metadata
 type EventData struct {
-	ID        uint64         `json:"id,omitempty"`
-	Operation EventOperation `json:"operation,omitempty"`
-	Path      []string       `json:"path,omitempty"`
-	Key       string         `json:"key,omitempty"`
-	Value     interface{}    `json:"value,omitempty"`
+	ID        uint64            `json:"id,omitempty"`
+	Operation EventOperation    `json:"operation,omitempty"`
+	Path      Path              `json:"path,omitempty"`
+	Key       string            `json:"key,omitempty"`
+	Value     interface{}       `json:"value,omitempty"`
+	OldValue  interface{}       `json:"oldValue,omitempty"`
+	Version   uint64            `json:"version,omitempty"`
+	Index     *int              `json:"index,omitempty"`
+	ExpiresAt *time.Time        `json:"expiresAt,omitempty"`
+	Reason    EventReason       `json:"reason,omitempty"`
+	Pattern   Path              `json:"pattern,omitempty"`
+	Params    map[string]string `json:"params,omitempty"`
+	Matches   []EventData       `json:"matches,omitempty"`
+	//Sequence orders the events of the committed mutations, the events of a mutation share it
+	Sequence uint64 `json:"sequence,omitempty"`
+	//Changes are the events of a ChangeSet, one per changed path, Leaves are the changed leaves among them
+	Changes   []EventData `json:"changes,omitempty"`
+	Leaves    []Path      `json:"leaves,omitempty"`
 	Timestamp time.Time
 }
 
@@ -49,17 +73,33 @@ func (e EventsSortedByTimestamp) Less(i, j int) bool {
 
 type notifier struct {
 	sync.Mutex
-	handlers    map[EventOperation][]chan<- EventData
-	handlersMap map[chan<- EventData][]EventOperation
+	//subscriptions is the trie of the subscribed path prefixes
+	subscriptions *subscriptionNode
+	subscribers   map[chan<- EventData]*subscriber
+	changes       *changeLog
+	//disconnected holds the channels closed by OverflowDisconnect until StopNotify is called for them
+	disconnected map[chan<- EventData]bool
+	//closed is set once the pending notifications are queued and the subscribers are closing
+	closed bool
 
-	notifyChannel chan EventData
+	//notifyMutex guards notifyClosed, it must not be the notifier lock since notifyLoop needs it to drain the channel.
+	//The notifications of a single call are sent together so the ones of a mutation can be batched.
+	notifyMutex   sync.Mutex
+	notifyChannel chan []EventData
+	//notifyClosed guarded by notifyMutex rejects the notifications once notifyChannel is closed
+	notifyClosed bool
+	//loopDone is closed when notifyLoop returns
+	loopDone chan struct{}
 }
 
-func newNotifier() *notifier {
+func newNotifier(changes *changeLog) *notifier {
 	n := &notifier{
-		handlers:      make(map[EventOperation][]chan<- EventData),
-		handlersMap:   make(map[chan<- EventData][]EventOperation),
-		notifyChannel: make(chan EventData, 10),
+		subscriptions: newSubscriptionNode(),
+		subscribers:   make(map[chan<- EventData]*subscriber),
+		changes:       changes,
+		disconnected:  make(map[chan<- EventData]bool),
+		notifyChannel: make(chan []EventData, 10),
+		loopDone:      make(chan struct{}),
 	}
 
 	go n.notifyLoop()
@@ -67,30 +107,144 @@ func newNotifier() *notifier {
 	return n
 }
 
+//Notify sends the events of the operations anywhere in the tree to c
 func (n *notifier) Notify(c chan<- EventData, operations ...EventOperation) error {
+	return n.NotifyPath(c, Path{}, operations...)
+}
+
+//Changes sends the events of the mutations committed after the one with the sequence number fromSeq to c,
+//first the retained ones and then the new ones as they are committed, without gaps or duplicates.
+//It returns ErrChangesUnavailable when some of them are no longer retained.
+func (n *notifier) Changes(c chan<- EventData, fromSeq uint64) error {
+	return n.notifyPath(c, Path{}, nil, &fromSeq,
+		[]EventOperation{EventOperationInsert, EventOperationUpdate, EventOperationDelete})
+}
+
+//NotifyPathFrom is NotifyPathWithOptions resuming after the mutation with the sequence number fromSeq like Changes.
+//The retained events c has already been sent by its other subscriptions are not sent again.
+func (n *notifier) NotifyPathFrom(c chan<- EventData, prefix Path, fromSeq uint64, options SubscriberOptions, operations ...EventOperation) error {
+	return n.notifyPath(c, prefix, &options, &fromSeq, operations)
+}
+
+//NotifyPath sends the events of the operations at prefix and below it to c, the events of the mutations
+//committed before it are not sent even when they are still being delivered to the others. Subscribing a channel
+//more than once to the same prefix and operation sends the events once and needs as many StopNotifyPath calls.
+//A new channel gets the default SubscriberOptions, the ones of a subscribed channel are kept.
+func (n *notifier) NotifyPath(c chan<- EventData, prefix Path, operations ...EventOperation) error {
+	return n.notifyPath(c, prefix, nil, nil, operations)
+}
+
+//NotifyPathWithOptions is NotifyPath setting how the events are queued for c, the options replace
+//the ones of a subscribed channel, its queued events are kept
+func (n *notifier) NotifyPathWithOptions(c chan<- EventData, prefix Path, options SubscriberOptions, operations ...EventOperation) error {
+	return n.notifyPath(c, prefix, &options, nil, operations)
+}
+
+//notifyPath subscribes c, the retained events after from are replayed when it is not nil
+func (n *notifier) notifyPath(c chan<- EventData, prefix Path, options *SubscriberOptions, from *uint64, operations []EventOperation) error {
 	if c == nil {
 		return errors.New("Invalid channel - nil")
 	}
 
+	prefix = prefix.Relative()
+	for _, key := range prefix {
+		if isPatternKey(key) {
+			return &InvalidPathError{Path: prefix.String(), Key: key, Reason: "patterns are not supported by subscriptions"}
+		}
+	}
+
+	opts := SubscriberOptions{}
+	if options != nil {
+		opts = *options
+	}
+
+	opts, err := opts.validate()
+	if err != nil {
+		return err
+	}
+
 	n.Lock()
 	defer n.Unlock()
 
-	for _, op := range operations {
-		if _, ok := n.handlers[op]; !ok {
-			n.handlers[op] = make([]chan<- EventData, 0)
-		}
+	if n.closed {
+		return ErrClosed
+	}
 
-		if _, ok := n.handlersMap[c]; !ok {
-			n.handlersMap[c] = make([]EventOperation, 0)
+	if n.disconnected[c] {
+		return ErrSubscriberDisconnected
+	}
+
+	//the notify loop matches the events holding the notifier lock, so every mutation after since
+	//is matched against the new subscription and every one up to it is in the change log
+	n.changes.mutex.Lock()
+	since := n.changes.sequence
+	var replay []EventData
+	if from != nil {
+		replay, err = n.changes.since(*from)
+	} else {
+		from = &since
+	}
+
+	n.changes.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s, ok := n.subscribers[c]
+	if !ok {
+		s = newSubscriber(c, opts, n.disconnect)
+		n.subscribers[c] = s
+	} else if options != nil {
+		s.mutex.Lock()
+		s.options = opts
+		s.cond.Broadcast()
+		s.mutex.Unlock()
+	}
+
+	matched := make([]EventData, 0)
+	for _, ev := range replay {
+		path := ev.Path.Relative()
+		if hasPathPrefix(path, prefix) && hasOperation(operations, ev.Operation) &&
+			!n.subscriptions.covered(c, path, ev.Operation, ev.Sequence) {
+			matched = append(matched, ev)
 		}
+	}
 
-		n.handlers[op] = append(n.handlers[op], c)
-		n.handlersMap[c] = append(n.handlersMap[c], op)
+	s.replay(matched)
+
+	n.subscriptions.add(prefix, c, operations, *from, since)
+	s.prefixes[prefix.String()] = prefix
+
+	return nil
+}
+
+//StopNotifyPath drops a subscription of c to each of the operations at prefix, all of them when no operations are given.
+//The queued events of c are dropped with its last subscription.
+func (n *notifier) StopNotifyPath(c chan<- EventData, prefix Path, operations ...EventOperation) error {
+	if c == nil {
+		return errors.New("Invalid channel - nil")
+	}
+
+	prefix = prefix.Relative()
+
+	n.Lock()
+	defer n.Unlock()
+
+	s, ok := n.subscribers[c]
+	if !ok || n.subscriptions.remove(prefix, c, operations) {
+		return nil
+	}
+
+	delete(s.prefixes, prefix.String())
+	if len(s.prefixes) == 0 {
+		delete(n.subscribers, c)
+		s.stop()
 	}
 
 	return nil
 }
 
+//StopNotify drops all the subscriptions of c and its queued events
 func (n *notifier) StopNotify(c chan<- EventData) error {
 	if c == nil {
 		return errors.New("Invalid channel - nil")
@@ -99,39 +253,131 @@ func (n *notifier) StopNotify(c chan<- EventData) error {
 	n.Lock()
 	defer n.Unlock()
 
-	operations := n.handlersMap[c]
-	delete(n.handlersMap, c)
-	for _, op := range operations {
-		channels := n.handlers[op]
-		//TODO: check performance since this uses reflection,
-		//shouldn't be a problem tho, there won't be many elements
-		index := funk.IndexOf(channels, c)
-		n.handlers[op] = append(channels[:index], channels[index+1:]...)
+	delete(n.disconnected, c)
+
+	s, ok := n.subscribers[c]
+	if !ok {
+		return nil
 	}
 
+	n.unsubscribe(s)
+	s.stop()
+
 	return nil
 }
 
-//TODO: does this need to stop at all?
+//SubscriberStats returns the delivery counters of c
+func (n *notifier) SubscriberStats(c chan<- EventData) (SubscriberStats, error) {
+	n.Lock()
+	s, ok := n.subscribers[c]
+	n.Unlock()
+
+	if !ok {
+		return SubscriberStats{}, ErrSubscriberNotFound
+	}
+
+	return s.stats(), nil
+}
+
+func hasOperation(operations []EventOperation, op EventOperation) bool {
+	for _, o := range operations {
+		if o == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+//unsubscribe removes all the subscriptions of s, the caller must hold the notifier lock
+func (n *notifier) unsubscribe(s *subscriber) {
+	for _, prefix := range s.prefixes {
+		n.subscriptions.remove(prefix, s.c, nil)
+	}
+
+	delete(n.subscribers, s.c)
+}
+
+//disconnect drops the subscriptions of a subscriber which overflowed with OverflowDisconnect
+func (n *notifier) disconnect(s *subscriber) {
+	n.Lock()
+	defer n.Unlock()
+
+	if n.subscribers[s.c] == s {
+		n.unsubscribe(s)
+		n.disconnected[s.c] = true
+	}
+}
+
 func (n *notifier) notifyLoop() {
-	for {
-		notification := <-n.notifyChannel
+	defer close(n.loopDone)
+
+	for notifications := range n.notifyChannel {
+		//the events are grouped by subscriber keeping their order
+		subscribers := make([]*subscriber, 0)
+		events := make(map[*subscriber][]EventData)
 
 		n.Lock()
-		for _, c := range n.handlers[notification.Operation] {
-			c <- notification
+		for _, notification := range notifications {
+			for _, c := range n.subscriptions.match(notification.Path.Relative(), notification.Operation, notification.Sequence) {
+				s := n.subscribers[c]
+				if _, ok := events[s]; !ok {
+					subscribers = append(subscribers, s)
+				}
+
+				events[s] = append(events[s], notification)
+			}
 		}
 
 		n.Unlock()
+
+		//the queues are filled without the notifier lock so a blocking subscriber can still be stopped
+		for _, s := range subscribers {
+			s.enqueueEvents(events[s])
+		}
 	}
-}
 
-func (n *notifier) notifyInternal(notifications ...EventData) {
 	n.Lock()
 	defer n.Unlock()
 
-	for _, notification := range notifications {
-		notification.Timestamp = time.Now().UTC()
-		n.notifyChannel <- notification
+	n.closed = true
+	for _, s := range n.subscribers {
+		s.close()
+	}
+
+	n.subscriptions = newSubscriptionNode()
+	n.subscribers = make(map[chan<- EventData]*subscriber)
+}
+
+//close queues the pending notifications and closes the channels of the subscribers once they receive their queued events
+func (n *notifier) close() {
+	n.notifyMutex.Lock()
+	if !n.notifyClosed {
+		n.notifyClosed = true
+		close(n.notifyChannel)
 	}
+
+	n.notifyMutex.Unlock()
+	<-n.loopDone
+}
+
+func (n *notifier) notifyInternal(notifications ...EventData) {
+	n.notifyMutex.Lock()
+	defer n.notifyMutex.Unlock()
+
+	if n.notifyClosed {
+		return
+	}
+
+	batch := make([]EventData, len(notifications))
+	for i, notification := range notifications {
+		//the events of the mutations are timestamped when they are committed
+		if notification.Timestamp.IsZero() {
+			notification.Timestamp = time.Now().UTC()
+		}
+
+		batch[i] = notification
+	}
+
+	n.notifyChannel <- batch
 }
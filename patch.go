@@ -0,0 +1,321 @@
+package liquiddb
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-errors/errors"
+)
+
+var (
+	//ErrInvalidPatch is returned when a patch operation is unknown or malformed
+	ErrInvalidPatch = errors.New("Invalid patch")
+	//ErrPatchTestFailed is returned when a test operation of a patch does not match
+	ErrPatchTestFailed = errors.New("Patch test failed")
+)
+
+//PatchOperation is a single operation of a JSON Patch (RFC 6902) document,
+//Path and From are JSON Pointers (RFC 6901)
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+//PatchError is returned when an operation of a patch fails, nothing of the patch is applied
+type PatchError struct {
+	Index     int
+	Operation PatchOperation
+	Err       error
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("Patch operation %d (%s %s) failed, %s", e.Index, e.Operation.Op, e.Operation.Path, e.Err)
+}
+
+//parsePointer parses a JSON Pointer into the keys it refers to
+func parsePointer(pointer string) (Path, error) {
+	if pointer == "" {
+		return Path{}, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, ErrInvalidPatch
+	}
+
+	keys := strings.Split(pointer[1:], "/")
+	for i, key := range keys {
+		keys[i] = strings.Replace(strings.Replace(key, "~1", "/", -1), "~0", "~", -1)
+	}
+
+	return keys, nil
+}
+
+//jsonEqual compares json values, numbers are equal regardless of their types
+func jsonEqual(a, b interface{}) bool {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+
+		for key, value := range av {
+			other, ok := bv[key]
+			if !ok || !jsonEqual(value, other) {
+				return false
+			}
+		}
+
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if rank := valueRank(a); rank < 4 && rank == valueRank(b) {
+		return compareValues(a, b) == 0
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+//patcher stages the operations of a patch in a transaction, the paths of the operations are relative to root
+type patcher struct {
+	tx   *Tx
+	root Path
+
+	//view is the overlay of the transaction, every operation stages records under root
+	//so they are applied to it as they are staged and it is never copied again
+	view *tree
+}
+
+func newPatcher(tx *Tx, root Path) patcher {
+	return patcher{tx: tx, root: root, view: tx.view(root)}
+}
+
+//lookup returns the value at path as the transaction sees it. The view holds the whole
+//patched document so that the staged splices of its arrays are replayed correctly.
+func (p patcher) lookup(path Path) (interface{}, bool) {
+	node := p.view.findNode(path, false)
+	if node == nil {
+		return nil, false
+	}
+
+	return p.view.getJSON(node), true
+}
+
+//arrayIndex returns the index an array element key refers to and whether path is an element of an array.
+//The key - refers to the index after the last element.
+func (p patcher) arrayIndex(path Path) (int, bool, error) {
+	node := p.view.findNode(path[:len(path)-1], false)
+	if node == nil {
+		return 0, false, ErrNotFound
+	}
+
+	if !node.IsArray() {
+		return 0, false, nil
+	}
+
+	key := path[len(path)-1]
+	length := node.childCount()
+	if key == "-" {
+		return length, true, nil
+	}
+
+	i, err := strconv.Atoi(key)
+	if err != nil || strconv.Itoa(i) != key {
+		return 0, true, ErrInvalidPatch
+	}
+
+	if i < 0 || i > length {
+		return 0, true, ErrIndexOutOfRange
+	}
+
+	return i, true, nil
+}
+
+//splice stages a splice of the array at path
+func (p patcher) splice(path Path, start, deleteCount int, items ...interface{}) error {
+	return p.tx.stage(logRecord{
+		Operation: logOperationSplice,
+		Path:      path,
+		Value:     items,
+		Index:     start,
+		Count:     deleteCount,
+	})
+}
+
+//set stages replacing whatever is at path with value
+func (p patcher) set(path Path, value interface{}) error {
+	if len(path.Relative()) == 0 {
+		if _, ok := value.(map[string]interface{}); !ok {
+			return ErrInvalidPatch
+		}
+	} else if _, ok := p.lookup(path); ok {
+		p.tx.Delete(path)
+	}
+
+	return p.tx.SetPath(path, value)
+}
+
+func (p patcher) add(path Path, value interface{}) error {
+	if len(path.Relative()) == 0 {
+		return p.set(path, value)
+	}
+
+	i, isArray, err := p.arrayIndex(path)
+	if err != nil {
+		return err
+	}
+
+	if isArray {
+		return p.splice(path[:len(path)-1], i, 0, value)
+	}
+
+	return p.set(path, value)
+}
+
+func (p patcher) remove(path Path) error {
+	if len(path.Relative()) == 0 {
+		return ErrInvalidPatch
+	}
+
+	if _, ok := p.lookup(path); !ok {
+		return ErrNotFound
+	}
+
+	i, isArray, err := p.arrayIndex(path)
+	if err != nil {
+		return err
+	}
+
+	if isArray {
+		return p.splice(path[:len(path)-1], i, 1)
+	}
+
+	p.tx.Delete(path)
+	return nil
+}
+
+func (p patcher) replace(path Path, value interface{}) error {
+	if _, ok := p.lookup(path); !ok {
+		return ErrNotFound
+	}
+
+	if len(path.Relative()) == 0 {
+		return p.set(path, value)
+	}
+
+	i, isArray, err := p.arrayIndex(path)
+	if err != nil {
+		return err
+	}
+
+	if isArray {
+		return p.splice(path[:len(path)-1], i, 1, value)
+	}
+
+	return p.set(path, value)
+}
+
+//resolve returns the path of a JSON Pointer relative to root
+func (p patcher) resolve(pointer string) (Path, error) {
+	keys, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append(Path{}, p.root...), keys...), nil
+}
+
+//apply stages a single patch operation
+func (p patcher) apply(op PatchOperation) error {
+	path, err := p.resolve(op.Path)
+	if err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case "add":
+		return p.add(path, op.Value)
+	case "remove":
+		return p.remove(path)
+	case "replace":
+		return p.replace(path, op.Value)
+	case "move", "copy":
+		from, err := p.resolve(op.From)
+		if err != nil {
+			return err
+		}
+
+		value, ok := p.lookup(from)
+		if !ok {
+			return ErrNotFound
+		}
+
+		if op.Op == "move" {
+			if reflect.DeepEqual(from, path) {
+				return nil
+			}
+
+			//a value cannot be moved into itself
+			if hasPathPrefix(path, from) {
+				return ErrInvalidPatch
+			}
+
+			if err := p.remove(from); err != nil {
+				return err
+			}
+		}
+
+		return p.add(path, value)
+	case "test":
+		value, ok := p.lookup(path)
+		if !ok {
+			return ErrNotFound
+		}
+
+		if !jsonEqual(value, op.Value) {
+			return ErrPatchTestFailed
+		}
+
+		return nil
+	default:
+		return ErrInvalidPatch
+	}
+}
+
+//ApplyPatch applies a JSON Patch (RFC 6902) document to the value at path atomically,
+//when one of its operations fails, including a test, nothing is changed and a PatchError is returned
+func (db LiquidDb) ApplyPatch(path Path, patch []PatchOperation) ([]EventData, error) {
+	return db.Transaction(func(tx *Tx) error {
+		p := newPatcher(tx, path)
+		for i, op := range patch {
+			if err := p.apply(op); err != nil {
+				return &PatchError{Index: i, Operation: op, Err: err}
+			}
+		}
+
+		return nil
+	})
+}
+
+//ApplyMergePatch applies a JSON Merge Patch (RFC 7386) document to the value at path atomically,
+//it has the same semantics as Update
+func (db LiquidDb) ApplyMergePatch(path Path, patch interface{}) ([]EventData, error) {
+	return db.Update(path, patch)
+}
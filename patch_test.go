@@ -0,0 +1,116 @@
+package liquiddb
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var doc = Path{"docs", "d1"}
+
+func patchDocument(t *testing.T, patch string) []PatchOperation {
+	var ops []PatchOperation
+	if err := json.Unmarshal([]byte(patch), &ops); err != nil {
+		t.Fatal(err)
+	}
+
+	return ops
+}
+
+func TestPatch_Apply(t *testing.T) {
+	db := New()
+	db.SetPath(doc, map[string]interface{}{
+		"title": "a",
+		"tags":  []interface{}{"x", "y"},
+		"meta":  map[string]interface{}{"a/b": 1, "old": true},
+	})
+
+	_, err := db.ApplyPatch(doc, patchDocument(t, `[
+		{"op": "test", "path": "/title", "value": "a"},
+		{"op": "replace", "path": "/title", "value": "b"},
+		{"op": "add", "path": "/tags/1", "value": "z"},
+		{"op": "add", "path": "/tags/-", "value": "w"},
+		{"op": "remove", "path": "/tags/0"},
+		{"op": "test", "path": "/meta/a~1b", "value": 1},
+		{"op": "move", "path": "/meta/new", "from": "/meta/old"},
+		{"op": "copy", "path": "/first", "from": "/tags/0"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, _ := db.Get(doc)
+	expected := map[string]interface{}{
+		"title": "b",
+		"tags":  []interface{}{"z", "y", "w"},
+		"meta":  map[string]interface{}{"a/b": 1, "new": true},
+		"first": "z",
+	}
+	if !reflect.DeepEqual(v.Value, expected) {
+		t.Fatalf("Invalid patched value %+v", v.Value)
+	}
+}
+
+func TestPatch_Atomic(t *testing.T) {
+	db := New()
+	db.SetPath(doc, map[string]interface{}{"title": "a", "count": 1})
+
+	_, err := db.ApplyPatch(doc, patchDocument(t, `[
+		{"op": "replace", "path": "/title", "value": "b"},
+		{"op": "test", "path": "/count", "value": 2}
+	]`))
+	patchErr, ok := err.(*PatchError)
+	if !ok || patchErr.Index != 1 || patchErr.Err != ErrPatchTestFailed {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	_, err = db.ApplyPatch(doc, patchDocument(t, `[{"op": "remove", "path": "/missing"}]`))
+	if patchErr, ok := err.(*PatchError); !ok || patchErr.Err != ErrNotFound {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	v, _ := db.Get(Path{"docs", "d1", "title"})
+	if v.Value != "a" {
+		t.Fatalf("Failed patch changed the value %+v", v.Value)
+	}
+}
+
+func TestPatch_MergePatch(t *testing.T) {
+	db := New()
+	db.SetPath(doc, map[string]interface{}{"title": "a", "author": map[string]interface{}{"name": "x", "email": "y"}})
+
+	var patch interface{}
+	json.Unmarshal([]byte(`{"title": "b", "author": {"email": null}}`), &patch)
+
+	if _, err := db.ApplyMergePatch(doc, patch); err != nil {
+		t.Fatal(err)
+	}
+
+	v, _ := db.Get(doc)
+	expected := map[string]interface{}{"title": "b", "author": map[string]interface{}{"name": "x"}}
+	if !reflect.DeepEqual(v.Value, expected) {
+		t.Fatalf("Invalid merge patched value %+v", v.Value)
+	}
+}
+
+func TestPatch_SingleView(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	db.SetPath(doc, map[string]interface{}{"tags": []interface{}{}})
+	db.Transaction(func(tx *Tx) error {
+		p := newPatcher(tx, doc)
+		for i := 0; i < 100; i++ {
+			if err := p.apply(PatchOperation{Op: "add", Path: "/tags/-", Value: i}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		//the operations were applied to the view the patch started with
+		if value, _ := p.lookup(append(doc, "tags", "99")); value != 99 || tx.overlay != p.view {
+			t.Fatalf("Invalid patched value %+v", value)
+		}
+
+		return nil
+	})
+}
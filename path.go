@@ -0,0 +1,169 @@
+package liquiddb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	//pathSeparator separates the keys in the string form of a path
+	pathSeparator = '.'
+	//pathEscape escapes a separator or itself inside a key
+	pathEscape = '\\'
+)
+
+//Path is a path in the tree, a leading TreeRoot key refers to the root and is optional.
+//The string form joins the keys with dots, dots and backslashes inside keys are escaped with a backslash.
+type Path []string
+
+//InvalidPathError is returned when a path cannot be parsed
+//or contains a key that cannot be written
+type InvalidPathError struct {
+	Path   string
+	Key    string
+	Reason string
+}
+
+func (e *InvalidPathError) Error() string {
+	return fmt.Sprintf("Invalid key %q in path %q, %s", e.Key, e.Path, e.Reason)
+}
+
+//EscapeKey escapes the dots and the backslashes of a key
+func EscapeKey(key string) string {
+	if !strings.ContainsAny(key, string([]rune{pathSeparator, pathEscape})) {
+		return key
+	}
+
+	var b strings.Builder
+	for _, r := range key {
+		if r == pathSeparator || r == pathEscape {
+			b.WriteRune(pathEscape)
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+//ParsePath parses the string form of a path, the empty string is the root
+func ParsePath(s string) (Path, error) {
+	path := Path{}
+	if s == "" {
+		return path, nil
+	}
+
+	var key strings.Builder
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			if r != pathSeparator && r != pathEscape {
+				return nil, &InvalidPathError{Path: s, Key: key.String(), Reason: fmt.Sprintf("invalid escape \\%c", r)}
+			}
+
+			key.WriteRune(r)
+			escaped = false
+		case r == pathEscape:
+			escaped = true
+		case r == pathSeparator:
+			path = append(path, key.String())
+			key.Reset()
+		default:
+			key.WriteRune(r)
+		}
+	}
+
+	if escaped {
+		return nil, &InvalidPathError{Path: s, Key: key.String(), Reason: "unterminated escape"}
+	}
+
+	path = append(path, key.String())
+	for _, k := range path {
+		if k == "" {
+			return nil, &InvalidPathError{Path: s, Key: k, Reason: "keys cannot be empty"}
+		}
+	}
+
+	return path, nil
+}
+
+//String returns the canonical string form of the path, ParsePath reverses it
+func (p Path) String() string {
+	keys := make([]string, len(p))
+	for i, key := range p {
+		keys[i] = EscapeKey(key)
+	}
+
+	return strings.Join(keys, string(pathSeparator))
+}
+
+//Relative returns the path without the optional leading TreeRoot key
+func (p Path) Relative() Path {
+	return trimRoot(p)
+}
+
+//validateKey returns why key cannot be written, keys that could be confused
+//with the root or with a pattern are reserved
+//Scope returns the part of the path relative to the root before its first pattern key,
+//every path a pattern can match is below it
+func (p Path) Scope() Path {
+	p = p.Relative()
+	for i, key := range p {
+		if isPatternKey(key) {
+			return p[:i]
+		}
+	}
+
+	return p
+}
+
+func validateKey(key string) string {
+	switch {
+	case key == "":
+		return "keys cannot be empty"
+	case key == TreeRoot:
+		return fmt.Sprintf("%q is reserved for the root", TreeRoot)
+	case isPatternKey(key):
+		return "pattern keys are reserved"
+	}
+
+	return ""
+}
+
+//Validate returns an InvalidPathError if the path contains a key that cannot be written
+func (p Path) Validate() error {
+	for _, key := range p.Relative() {
+		if reason := validateKey(key); reason != "" {
+			return &InvalidPathError{Path: p.String(), Key: key, Reason: reason}
+		}
+	}
+
+	return nil
+}
+
+//validateValue returns an InvalidPathError if a key of the objects in value cannot be written
+func validateValue(path Path, value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			childPath := appendPath(path, key)
+			if reason := validateKey(key); reason != "" {
+				return &InvalidPathError{Path: childPath.String(), Key: key, Reason: reason}
+			}
+
+			if err := validateValue(childPath, child); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			if err := validateValue(appendPath(path, strconv.Itoa(i)), child); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package liquiddb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPath_ParseString(t *testing.T) {
+	cases := map[string]Path{
+		"":                {},
+		"a":               {"a"},
+		"a.b":             {"a", "b"},
+		`example\.com.ip`: {"example.com", "ip"},
+		`back\\slash`:     {`back\slash`},
+		`a\\.b`:           {`a\`, "b"},
+	}
+
+	for s, expected := range cases {
+		path, err := ParsePath(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(path, expected) {
+			t.Fatalf("Invalid parsed path of %s %#v", s, path)
+		}
+
+		if path.String() != s {
+			t.Fatalf("Invalid string of %#v %s", path, path.String())
+		}
+	}
+
+	for _, s := range []string{"a..b", "a.", `a\b`, `a\`} {
+		if _, err := ParsePath(s); err == nil {
+			t.Fatalf("Parsed invalid path %s", s)
+		}
+	}
+}
+
+func TestPath_EscapedKeys(t *testing.T) {
+	db := New()
+
+	if _, err := db.SetPath(Path{"hosts", "example.com"}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetPath(Path{"hosts", "example", "com"}, 2)
+
+	v, err := db.GetByString(`hosts.example\.com`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v.(EventData).Value != 1 {
+		t.Fatalf("Escaped key collided %+v", v)
+	}
+
+	if _, ok := db.DeleteByString(`hosts.example\.com`); !ok {
+		t.Fatal("Could not delete escaped key")
+	}
+
+	if v, _ := db.Get(Path{"hosts", "example", "com"}); v.Value != 2 {
+		t.Fatalf("Deleted wrong key %+v", v.Value)
+	}
+}
+
+func TestPath_ReservedKeys(t *testing.T) {
+	db := New()
+
+	invalid := []Path{{"root", "root"}, {"a", "root"}, {"a", "*"}, {"**"}, {"{id}"}, {"a", ""}}
+	for _, path := range invalid {
+		_, err := db.SetPath(path, 1)
+		if _, ok := err.(*InvalidPathError); !ok {
+			t.Fatalf("Invalid error for %#v %v", path, err)
+		}
+	}
+
+	_, err := db.Set(map[string]interface{}{"a": map[string]interface{}{"root": 1}})
+	pathErr, ok := err.(*InvalidPathError)
+	if !ok || pathErr.Key != TreeRoot || pathErr.Path != "a.root" {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	_, err = db.Transaction(func(tx *Tx) error {
+		return tx.SetPath(Path{"a", "*"}, 1)
+	})
+	if _, ok := err.(*InvalidPathError); !ok {
+		t.Fatalf("Invalid transaction error %v", err)
+	}
+
+	if _, err := db.SetPath(Path{TreeRoot, "a"}, 1); err != nil {
+		t.Fatalf("Leading root rejected %v", err)
+	}
+}
+
+func TestPath_Scope(t *testing.T) {
+	scopes := map[string]Path{
+		"root.a.b": {"a", "b"},
+		"a.*.c":    {"a"},
+		"a.b.**":   {"a", "b"},
+		"{id}.c":   {},
+		"root":     {},
+	}
+
+	for s, expected := range scopes {
+		path, err := ParsePath(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if scope := path.Scope(); !reflect.DeepEqual(scope, expected) {
+			t.Fatalf("Invalid scope of %s %#v", s, scope)
+		}
+	}
+}
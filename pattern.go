@@ -0,0 +1,217 @@
+package liquiddb
+
+import (
+	"context"
+	"strings"
+)
+
+const (
+	//PatternAny matches exactly one key of a path
+	PatternAny = "*"
+	//PatternAnyDepth matches any number of keys of a path, including none
+	PatternAnyDepth = "**"
+)
+
+//patternCapture returns the name of a capture key such as {uid}
+func patternCapture(key string) (string, bool) {
+	if len(key) > 2 && strings.HasPrefix(key, "{") && strings.HasSuffix(key, "}") {
+		return key[1 : len(key)-1], true
+	}
+
+	return "", false
+}
+
+func isPatternKey(key string) bool {
+	_, capture := patternCapture(key)
+	return capture || key == PatternAny || key == PatternAnyDepth
+}
+
+//IsPattern returns whether path contains *, ** or captures such as {uid}
+func IsPattern(path Path) bool {
+	for _, key := range path {
+		if isPatternKey(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func copyParams(params map[string]string) map[string]string {
+	res := make(map[string]string, len(params)+1)
+	for k, v := range params {
+		res[k] = v
+	}
+
+	return res
+}
+
+//MatchPath matches path against pattern. * matches exactly one key, ** matches any number
+//of keys and {name} matches exactly one key and captures it under name in the returned params.
+func MatchPath(pattern, path Path) (map[string]string, bool) {
+	return matchPath(trimRoot(pattern), trimRoot(path), map[string]string{})
+}
+
+func matchPath(pattern, path Path, params map[string]string) (map[string]string, bool) {
+	if len(pattern) == 0 {
+		return params, len(path) == 0
+	}
+
+	key := pattern[0]
+	if key == PatternAnyDepth {
+		for i := 0; i <= len(path); i++ {
+			if res, ok := matchPath(pattern[1:], path[i:], params); ok {
+				return res, true
+			}
+		}
+
+		return nil, false
+	}
+
+	if len(path) == 0 {
+		return nil, false
+	}
+
+	if name, ok := patternCapture(key); ok {
+		params = copyParams(params)
+		params[name] = path[0]
+	} else if key != PatternAny && key != path[0] {
+		return nil, false
+	}
+
+	return matchPath(pattern[1:], path[1:], params)
+}
+
+type patternMatch struct {
+	node   *Node
+	params map[string]string
+}
+
+//findMatches returns every node matching pattern once, the root is never matched
+func (t *tree) findMatches(pattern Path) []patternMatch {
+	matches := make([]patternMatch, 0)
+	seen := make(map[*Node]bool)
+
+	var match func(node *Node, pattern Path, params map[string]string)
+	match = func(node *Node, pattern Path, params map[string]string) {
+		if len(pattern) == 0 {
+			if node != t.root && !seen[node] {
+				seen[node] = true
+				matches = append(matches, patternMatch{node, params})
+			}
+
+			return
+		}
+
+		key := pattern[0]
+		switch name, capture := patternCapture(key); {
+		case key == PatternAnyDepth:
+			match(node, pattern[1:], params)
+			for _, child := range node.childNodes() {
+				match(child, pattern, params)
+			}
+		case key == PatternAny:
+			for _, child := range node.childNodes() {
+				match(child, pattern[1:], params)
+			}
+		case capture:
+			for _, child := range node.childNodes() {
+				childParams := copyParams(params)
+				childParams[name] = child.Key
+				match(child, pattern[1:], childParams)
+			}
+		default:
+			if child, ok := node.child(key); ok {
+				match(child, pattern[1:], params)
+			}
+		}
+	}
+
+	match(t.root, trimRoot(pattern), map[string]string{})
+	return matches
+}
+
+//getMatches creates the event of a get of pattern, it holds the event of every matched node
+func (t *tree) getMatches(ctx context.Context, pattern Path) (EventData, error) {
+	res := EventData{
+		Key:       pattern[len(pattern)-1],
+		Operation: EventOperationGet,
+		Path:      pattern,
+		Pattern:   pattern,
+		Matches:   make([]EventData, 0),
+	}
+
+	for _, m := range t.findMatches(pattern) {
+		if err := ctx.Err(); err != nil {
+			return EventData{}, err
+		}
+
+		ev, err := t.getNode(ctx, m.node, m.node.Path)
+		if err != nil {
+			return EventData{}, err
+		}
+
+		ev.Pattern = pattern
+		ev.Params = m.params
+		res.Matches = append(res.Matches, ev)
+	}
+
+	return res, nil
+}
+
+//exists returns whether there is a node at path or a match of the pattern
+func (t *tree) exists(path Path) bool {
+	if IsPattern(path) {
+		return len(t.findMatches(path)) > 0
+	}
+
+	return t.findNode(path, false) != nil
+}
+
+//deleteMatches deletes every node matching pattern as a single write,
+//the events of each deleted subtree hold the params of its match
+func (t *tree) deleteMatches(pattern Path) ([]EventData, bool) {
+	matches := t.findMatches(pattern)
+	if len(matches) == 0 {
+		return nil, false
+	}
+
+	revision := t.nextRevision()
+	eventData := make([]EventData, 0)
+	elements := make([]patternMatch, 0)
+	for _, m := range matches {
+		//the node could be a descendant of an already deleted match
+		node := t.findNode(m.node.Path, false)
+		if node == nil {
+			continue
+		}
+
+		if parent := t.parent(node); parent != nil && parent.IsArray() {
+			elements = append(elements, m)
+			continue
+		}
+
+		for _, ev := range t.deleteNode(node, revision) {
+			ev.Pattern = pattern
+			ev.Params = m.params
+			eventData = append(eventData, ev)
+		}
+	}
+
+	//the elements of arrays are removed last to first, so removing one never moves the ones left to remove
+	for i := len(elements) - 1; i >= 0; i-- {
+		m := elements[i]
+		node := t.findNode(m.node.Path, false)
+		if node == nil {
+			continue
+		}
+
+		for _, ev := range t.removeElement(node) {
+			ev.Pattern = pattern
+			ev.Params = m.params
+			eventData = append(eventData, ev)
+		}
+	}
+
+	return eventData, true
+}
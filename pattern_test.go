@@ -0,0 +1,91 @@
+package liquiddb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var users = map[string]interface{}{
+	"users": map[string]interface{}{
+		"u1": map[string]interface{}{"email": "a@x", "tmp": 1},
+		"u2": map[string]interface{}{"email": "b@x", "profile": map[string]interface{}{"tmp": 2}},
+	},
+}
+
+func TestMatchPath(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		params  map[string]string
+		ok      bool
+	}{
+		{"users.*.email", "users.u1.email", map[string]string{}, true},
+		{"users.*.email", "users.u1.profile.email", nil, false},
+		{"users.**.email", "users.u1.profile.email", map[string]string{}, true},
+		{"users.**", "users", map[string]string{}, true},
+		{"users.{uid}.email", "users.u1.email", map[string]string{"uid": "u1"}, true},
+		{"{a}.{b}", "users.u1", map[string]string{"a": "users", "b": "u1"}, true},
+		{"users.{uid}", "users", nil, false},
+	}
+
+	for _, c := range cases {
+		params, ok := MatchPath(strings.Split(c.pattern, "."), strings.Split(c.path, "."))
+		if ok != c.ok || (ok && !reflect.DeepEqual(params, c.params)) {
+			t.Fatalf("Invalid match of %s against %s: %v %+v", c.path, c.pattern, ok, params)
+		}
+	}
+}
+
+func TestPattern_Get(t *testing.T) {
+	db := New()
+	db.Set(users)
+
+	v, err := db.Get(strings.Split("users.{uid}.email", "."))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(v.Matches) != 2 {
+		t.Fatalf("Invalid matches %+v", v.Matches)
+	}
+
+	for i, uid := range []string{"u1", "u2"} {
+		m := v.Matches[i]
+		if !reflect.DeepEqual(m.Path, Path{"users", uid, "email"}) || m.Params["uid"] != uid {
+			t.Fatalf("Invalid match %+v", m)
+		}
+	}
+
+	v, _ = db.Get(strings.Split("**.tmp", "."))
+	if len(v.Matches) != 2 || v.Matches[1].Value != 2 {
+		t.Fatalf("Invalid any depth matches %+v", v.Matches)
+	}
+}
+
+func TestPattern_Delete(t *testing.T) {
+	db := New()
+	db.Set(users)
+
+	ops, ok := db.Delete(strings.Split("users.*.**.tmp", "."))
+	if !ok || len(ops) != 2 {
+		t.Fatalf("Invalid delete events %+v", ops)
+	}
+
+	if ops[0].Version != ops[1].Version {
+		t.Fatalf("Pattern delete is not a single write %+v", ops)
+	}
+
+	v, _ := db.Get([]string{"users"})
+	expected := map[string]interface{}{
+		"u1": map[string]interface{}{"email": "a@x"},
+		"u2": map[string]interface{}{"email": "b@x", "profile": nil},
+	}
+	if !reflect.DeepEqual(v.Value, expected) {
+		t.Fatalf("Invalid value after pattern delete %+v", v.Value)
+	}
+
+	if _, ok := db.Delete(strings.Split("users.*.missing", ".")); ok {
+		t.Fatal("Deleted without matches")
+	}
+}
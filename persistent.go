@@ -0,0 +1,312 @@
+package liquiddb
+
+//pmap is a persistent AVL tree mapping the keys of children to their nodes in compareKeys order.
+//The nil pmap is the empty map, changes return a new map sharing the unchanged subtrees with the old one.
+type pmap struct {
+	key    string
+	value  *Node
+	left   *pmap
+	right  *pmap
+	height int
+	size   int
+}
+
+func newPmap(key string, value *Node, left, right *pmap) *pmap {
+	height := left.getHeight()
+	if right.getHeight() > height {
+		height = right.getHeight()
+	}
+
+	return &pmap{
+		key:    key,
+		value:  value,
+		left:   left,
+		right:  right,
+		height: height + 1,
+		size:   left.len() + right.len() + 1,
+	}
+}
+
+func (m *pmap) getHeight() int {
+	if m == nil {
+		return 0
+	}
+
+	return m.height
+}
+
+func (m *pmap) len() int {
+	if m == nil {
+		return 0
+	}
+
+	return m.size
+}
+
+//balancePmap creates a node from its parts, rotating them when the heights of the sides differ by more than one
+func balancePmap(key string, value *Node, left, right *pmap) *pmap {
+	switch {
+	case left.getHeight() > right.getHeight()+1:
+		if left.left.getHeight() >= left.right.getHeight() {
+			return newPmap(left.key, left.value, left.left, newPmap(key, value, left.right, right))
+		}
+
+		lr := left.right
+		return newPmap(lr.key, lr.value,
+			newPmap(left.key, left.value, left.left, lr.left),
+			newPmap(key, value, lr.right, right))
+	case right.getHeight() > left.getHeight()+1:
+		if right.right.getHeight() >= right.left.getHeight() {
+			return newPmap(right.key, right.value, newPmap(key, value, left, right.left), right.right)
+		}
+
+		rl := right.left
+		return newPmap(rl.key, rl.value,
+			newPmap(key, value, left, rl.left),
+			newPmap(right.key, right.value, rl.right, right.right))
+	default:
+		return newPmap(key, value, left, right)
+	}
+}
+
+func (m *pmap) get(key string) (*Node, bool) {
+	for n := m; n != nil; {
+		switch c := compareKeys(key, n.key); {
+		case c < 0:
+			n = n.left
+		case c > 0:
+			n = n.right
+		default:
+			return n.value, true
+		}
+	}
+
+	return nil, false
+}
+
+//set returns a map where key holds value
+func (m *pmap) set(key string, value *Node) *pmap {
+	if m == nil {
+		return newPmap(key, value, nil, nil)
+	}
+
+	switch c := compareKeys(key, m.key); {
+	case c < 0:
+		return balancePmap(m.key, m.value, m.left.set(key, value), m.right)
+	case c > 0:
+		return balancePmap(m.key, m.value, m.left, m.right.set(key, value))
+	default:
+		return newPmap(key, value, m.left, m.right)
+	}
+}
+
+//remove returns a map without key
+func (m *pmap) remove(key string) *pmap {
+	if m == nil {
+		return nil
+	}
+
+	switch c := compareKeys(key, m.key); {
+	case c < 0:
+		return balancePmap(m.key, m.value, m.left.remove(key), m.right)
+	case c > 0:
+		return balancePmap(m.key, m.value, m.left, m.right.remove(key))
+	}
+
+	if m.left == nil {
+		return m.right
+	}
+
+	if m.right == nil {
+		return m.left
+	}
+
+	min := m.right
+	for min.left != nil {
+		min = min.left
+	}
+
+	return balancePmap(min.key, min.value, m.left, m.right.removeMin())
+}
+
+func (m *pmap) removeMin() *pmap {
+	if m.left == nil {
+		return m.right
+	}
+
+	return balancePmap(m.key, m.value, m.left.removeMin(), m.right)
+}
+
+//each calls f with the values in key order
+func (m *pmap) each(f func(value *Node)) {
+	if m == nil {
+		return
+	}
+
+	m.left.each(f)
+	f(m.value)
+	m.right.each(f)
+}
+
+//entryTree is a persistent AVL tree of index entries ordered by compareIndexEntries. Like pmap
+//the nil entryTree is the empty tree and changes return a new tree sharing the unchanged subtrees,
+//the sizes of the subtrees find the entries by their position in O(log n).
+type entryTree struct {
+	entry  indexEntry
+	left   *entryTree
+	right  *entryTree
+	height int
+	size   int
+}
+
+func newEntryTree(entry indexEntry, left, right *entryTree) *entryTree {
+	height := left.getHeight()
+	if right.getHeight() > height {
+		height = right.getHeight()
+	}
+
+	return &entryTree{
+		entry:  entry,
+		left:   left,
+		right:  right,
+		height: height + 1,
+		size:   left.len() + right.len() + 1,
+	}
+}
+
+func (e *entryTree) getHeight() int {
+	if e == nil {
+		return 0
+	}
+
+	return e.height
+}
+
+func (e *entryTree) len() int {
+	if e == nil {
+		return 0
+	}
+
+	return e.size
+}
+
+//balanceEntries mirrors balancePmap
+func balanceEntries(entry indexEntry, left, right *entryTree) *entryTree {
+	switch {
+	case left.getHeight() > right.getHeight()+1:
+		if left.left.getHeight() >= left.right.getHeight() {
+			return newEntryTree(left.entry, left.left, newEntryTree(entry, left.right, right))
+		}
+
+		lr := left.right
+		return newEntryTree(lr.entry,
+			newEntryTree(left.entry, left.left, lr.left),
+			newEntryTree(entry, lr.right, right))
+	case right.getHeight() > left.getHeight()+1:
+		if right.right.getHeight() >= right.left.getHeight() {
+			return newEntryTree(right.entry, newEntryTree(entry, left, right.left), right.right)
+		}
+
+		rl := right.left
+		return newEntryTree(rl.entry,
+			newEntryTree(entry, left, rl.left),
+			newEntryTree(right.entry, rl.right, right.right))
+	default:
+		return newEntryTree(entry, left, right)
+	}
+}
+
+//insert returns a tree holding entry, an equal entry is replaced
+func (e *entryTree) insert(entry indexEntry) *entryTree {
+	if e == nil {
+		return newEntryTree(entry, nil, nil)
+	}
+
+	switch c := compareIndexEntries(entry, e.entry); {
+	case c < 0:
+		return balanceEntries(e.entry, e.left.insert(entry), e.right)
+	case c > 0:
+		return balanceEntries(e.entry, e.left, e.right.insert(entry))
+	default:
+		return newEntryTree(entry, e.left, e.right)
+	}
+}
+
+//remove returns a tree without entry
+func (e *entryTree) remove(entry indexEntry) *entryTree {
+	if e == nil {
+		return nil
+	}
+
+	switch c := compareIndexEntries(entry, e.entry); {
+	case c < 0:
+		return balanceEntries(e.entry, e.left.remove(entry), e.right)
+	case c > 0:
+		return balanceEntries(e.entry, e.left, e.right.remove(entry))
+	}
+
+	if e.left == nil {
+		return e.right
+	}
+
+	if e.right == nil {
+		return e.left
+	}
+
+	min := e.right
+	for min.left != nil {
+		min = min.left
+	}
+
+	return balanceEntries(min.entry, e.left, e.right.removeMin())
+}
+
+func (e *entryTree) removeMin() *entryTree {
+	if e.left == nil {
+		return e.right
+	}
+
+	return balanceEntries(e.entry, e.left.removeMin(), e.right)
+}
+
+//search returns the position of the first entry for which f is true like sort.Search,
+//f must be false for the entries before it and true for the ones after it
+func (e *entryTree) search(f func(entry indexEntry) bool) int {
+	position := 0
+	for n := e; n != nil; {
+		if f(n.entry) {
+			n = n.left
+		} else {
+			position += n.left.len() + 1
+			n = n.right
+		}
+	}
+
+	return position
+}
+
+//slice returns the entries at the positions from up to to
+func (e *entryTree) slice(from, to int) []indexEntry {
+	res := make([]indexEntry, 0, to-from)
+	e.collect(from, to, &res)
+	return res
+}
+
+func (e *entryTree) collect(from, to int, res *[]indexEntry) {
+	if e == nil || from >= to {
+		return
+	}
+
+	leftLen := e.left.len()
+	if from < leftLen {
+		e.left.collect(from, to, res)
+	}
+
+	if from <= leftLen && leftLen < to {
+		*res = append(*res, e.entry)
+	}
+
+	if to > leftLen+1 {
+		e.right.collect(from-leftLen-1, to-leftLen-1, res)
+	}
+}
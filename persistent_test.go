@@ -0,0 +1,119 @@
+package liquiddb
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func pmapKeys(m *pmap) []string {
+	keys := make([]string, 0)
+	m.each(func(value *Node) {
+		keys = append(keys, value.Key)
+	})
+
+	return keys
+}
+
+func TestPmap_SetRemove(t *testing.T) {
+	var m *pmap
+	expected := make(map[string]bool)
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 2000; i++ {
+		key := strconv.Itoa(r.Intn(500))
+		if r.Intn(3) == 0 {
+			m = m.remove(key)
+			delete(expected, key)
+		} else {
+			m = m.set(key, &Node{Key: key})
+			expected[key] = true
+		}
+	}
+
+	keys := pmapKeys(m)
+	if len(keys) != len(expected) || m.len() != len(expected) {
+		t.Fatalf("Invalid length %d, expected %d", len(keys), len(expected))
+	}
+
+	if !sort.SliceIsSorted(keys, func(i, j int) bool { return compareKeys(keys[i], keys[j]) < 0 }) {
+		t.Fatal("Keys are not ordered")
+	}
+
+	for key := range expected {
+		if v, ok := m.get(key); !ok || v.Key != key {
+			t.Fatalf("Missing key %s", key)
+		}
+	}
+
+	//an AVL tree of n keys is at most about 1.44 log2(n) high
+	if m.getHeight() > 15 {
+		t.Fatalf("Unbalanced map of height %d", m.getHeight())
+	}
+}
+
+func TestPmap_Persistent(t *testing.T) {
+	var m *pmap
+	for _, key := range []string{"a", "b", "c"} {
+		m = m.set(key, &Node{Key: key})
+	}
+
+	changed := m.set("d", &Node{Key: "d"}).remove("a")
+	if keys := pmapKeys(m); len(keys) != 3 || keys[0] != "a" {
+		t.Fatalf("Old map changed %v", keys)
+	}
+
+	if keys := pmapKeys(changed); len(keys) != 3 || keys[0] != "b" || keys[2] != "d" {
+		t.Fatalf("Invalid new map %v", keys)
+	}
+}
+
+func TestEntryTree_Positions(t *testing.T) {
+	var e *entryTree
+	expected := make(map[string]float64)
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 2000; i++ {
+		key := strconv.Itoa(r.Intn(300))
+		if value, ok := expected[key]; ok {
+			e = e.remove(indexEntry{key, value})
+			delete(expected, key)
+		}
+
+		if r.Intn(3) != 0 {
+			value := float64(r.Intn(50))
+			e = e.insert(indexEntry{key, value})
+			expected[key] = value
+		}
+	}
+
+	sorted := make([]indexEntry, 0, len(expected))
+	for key, value := range expected {
+		sorted = append(sorted, indexEntry{key, value})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return compareIndexEntries(sorted[i], sorted[j]) < 0 })
+
+	if e.len() != len(sorted) || e.getHeight() > 15 {
+		t.Fatalf("Invalid tree of length %d and height %d, expected length %d", e.len(), e.getHeight(), len(sorted))
+	}
+
+	for _, bounds := range [][2]int{{0, len(sorted)}, {3, 17}, {len(sorted) - 1, len(sorted)}, {5, 5}} {
+		entries := e.slice(bounds[0], bounds[1])
+		for i, entry := range entries {
+			if entry != sorted[bounds[0]+i] {
+				t.Fatalf("Invalid entry %d of %v %+v, expected %+v", i, bounds, entry, sorted[bounds[0]+i])
+			}
+		}
+
+		if len(entries) != bounds[1]-bounds[0] {
+			t.Fatalf("Invalid slice %v of length %d", bounds, len(entries))
+		}
+	}
+
+	from := e.search(func(entry indexEntry) bool { return compareValues(entry.value, 25.0) >= 0 })
+	expectedFrom := sort.Search(len(sorted), func(i int) bool { return compareValues(sorted[i].value, 25.0) >= 0 })
+	if from != expectedFrom {
+		t.Fatalf("Invalid position %d, expected %d", from, expectedFrom)
+	}
+}
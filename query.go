@@ -0,0 +1,300 @@
+package liquiddb
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/go-errors/errors"
+)
+
+//QueryOrder is what the children are ordered by in a Query
+type QueryOrder string
+
+const (
+	//QueryOrderByKey orders the children by their keys, the same way Scan does
+	QueryOrderByKey = QueryOrder("key")
+	//QueryOrderByValue orders the children by their values
+	QueryOrderByValue = QueryOrder("value")
+	//QueryOrderByChild orders the children by the value of their descendant at Query.Child
+	QueryOrderByChild = QueryOrder("child")
+)
+
+var (
+	//ErrInvalidQuery is returned when a query has an unknown order or both limits set
+	ErrInvalidQuery = errors.New("Invalid query")
+)
+
+//Query selects the children of a node. The children are ordered by OrderBy, values are ordered
+//null, false, true, numbers, strings, objects and children with equal values are ordered by key.
+//StartAt, EndAt and EqualTo are inclusive bounds compared the same way, nil means unbounded.
+type Query struct {
+	OrderBy      QueryOrder  `json:"orderBy,omitempty"`
+	Child        []string    `json:"child,omitempty"`
+	StartAt      interface{} `json:"startAt,omitempty"`
+	EndAt        interface{} `json:"endAt,omitempty"`
+	EqualTo      interface{} `json:"equalTo,omitempty"`
+	LimitToFirst int         `json:"limitToFirst,omitempty"`
+	LimitToLast  int         `json:"limitToLast,omitempty"`
+}
+
+//valueRank is the position of the type of a value in the query order
+func valueRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return 2
+	case string, []byte:
+		return 3
+	default:
+		return 4
+	}
+}
+
+//toFloat converts any numeric value to float64
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+//compareValues orders values null, false, true, numbers, strings, objects,
+//objects and arrays are all equal to each other
+func compareValues(a, b interface{}) int {
+	aRank, bRank := valueRank(a), valueRank(b)
+	if aRank != bRank {
+		if aRank < bRank {
+			return -1
+		}
+
+		return 1
+	}
+
+	switch aRank {
+	case 1:
+		aBool, bBool := a.(bool), b.(bool)
+		if aBool == bBool {
+			return 0
+		} else if !aBool {
+			return -1
+		}
+
+		return 1
+	case 2:
+		aNum, _ := toFloat(a)
+		bNum, _ := toFloat(b)
+		if aNum < bNum {
+			return -1
+		} else if aNum > bNum {
+			return 1
+		}
+	case 3:
+		return bytes.Compare(toBytes(a), toBytes(b))
+	}
+
+	return 0
+}
+
+func toBytes(v interface{}) []byte {
+	if s, ok := v.(string); ok {
+		return []byte(s)
+	}
+
+	return v.([]byte)
+}
+
+type queryChild struct {
+	node  *Node
+	value interface{}
+}
+
+//Query returns the children of the node at path selected by the query, in its order
+func (t *tree) Query(path Path, query Query) ([]EventData, error) {
+	if query.LimitToFirst > 0 && query.LimitToLast > 0 {
+		return nil, ErrInvalidQuery
+	}
+
+	node := t.findNode(path, false)
+	if node == nil {
+		return nil, ErrNotFound
+	}
+
+	if query.OrderBy == QueryOrderByChild {
+		if idx := t.indexes.find(path, query.Child); idx != nil {
+			return t.queryIndex(node, idx, query), nil
+		}
+	}
+
+	var compare func(a, b queryChild) int
+	switch query.OrderBy {
+	case QueryOrderByKey, "":
+		compare = func(a, b queryChild) int {
+			return compareKeys(a.node.Key, b.node.Key)
+		}
+	case QueryOrderByValue, QueryOrderByChild:
+		compare = func(a, b queryChild) int {
+			if c := compareValues(a.value, b.value); c != 0 {
+				return c
+			}
+
+			return compareKeys(a.node.Key, b.node.Key)
+		}
+	default:
+		return nil, ErrInvalidQuery
+	}
+
+	children := make([]queryChild, 0)
+	for _, child := range node.childNodes() {
+		c := queryChild{node: child}
+		switch query.OrderBy {
+		case QueryOrderByValue:
+			c.value = t.jsonValue(child)
+		case QueryOrderByChild:
+			if field := t.findDescendant(child, query.Child); field != nil {
+				c.value = t.jsonValue(field)
+			}
+		}
+
+		children = append(children, c)
+	}
+
+	sort.SliceStable(children, func(i, j int) bool {
+		return compare(children[i], children[j]) < 0
+	})
+
+	startAt, endAt := query.bounds()
+	inBounds := func(c queryChild) bool {
+		var lower, upper int
+		if query.OrderBy == QueryOrderByKey || query.OrderBy == "" {
+			if startAt != nil {
+				lower = compareKeys(c.node.Key, fmt.Sprint(startAt))
+			}
+			if endAt != nil {
+				upper = compareKeys(c.node.Key, fmt.Sprint(endAt))
+			}
+		} else {
+			if startAt != nil {
+				lower = compareValues(c.value, startAt)
+			}
+			if endAt != nil {
+				upper = compareValues(c.value, endAt)
+			}
+		}
+
+		return lower >= 0 && upper <= 0
+	}
+
+	selected := make([]queryChild, 0, len(children))
+	for _, c := range children {
+		if inBounds(c) {
+			selected = append(selected, c)
+		}
+	}
+
+	from, to := query.limit(len(selected))
+	res := make([]EventData, 0, to-from)
+	for _, c := range selected[from:to] {
+		res = append(res, t.queryResult(c.node))
+	}
+
+	return res, nil
+}
+
+//queryIndex selects the children of node through the index, without walking all of them
+func (t *tree) queryIndex(node *Node, idx *index, query Query) []EventData {
+	from, to := idx.bounds(query.bounds())
+	limitFrom, limitTo := query.limit(to - from)
+
+	res := make([]EventData, 0, limitTo-limitFrom)
+	for _, entry := range idx.entries.slice(from+limitFrom, from+limitTo) {
+		if child, ok := node.child(entry.key); ok {
+			res = append(res, t.queryResult(child))
+		}
+	}
+
+	return res
+}
+
+func (t *tree) queryResult(node *Node) EventData {
+	return EventData{
+		Key:       node.Key,
+		Operation: EventOperationGet,
+		Path:      node.Path,
+		Value:     t.jsonValue(node),
+		Version:   node.GetVersion(),
+		Index:     t.index(node),
+		ExpiresAt: node.expiresAt(),
+	}
+}
+
+//bounds returns the inclusive bounds of the query, EqualTo is both of them
+func (q Query) bounds() (interface{}, interface{}) {
+	if q.EqualTo != nil {
+		return q.EqualTo, q.EqualTo
+	}
+
+	return q.StartAt, q.EndAt
+}
+
+//limit returns the range of the selected children that the limits of the query leave
+func (q Query) limit(selected int) (int, int) {
+	if q.LimitToFirst > 0 && selected > q.LimitToFirst {
+		return 0, q.LimitToFirst
+	}
+
+	if q.LimitToLast > 0 && selected > q.LimitToLast {
+		return selected - q.LimitToLast, selected
+	}
+
+	return 0, selected
+}
+
+//findDescendant finds the node at path relative to node
+func (t *tree) findDescendant(node *Node, path Path) *Node {
+	for _, key := range path {
+		child, ok := node.child(key)
+		if !ok {
+			return nil
+		}
+
+		node = child
+	}
+
+	return node
+}
+
+//Query returns the children of the node at path selected by the query, in its order
+func (db LiquidDb) Query(path Path, query Query) ([]EventData, error) {
+	db.treeMutex.RLock()
+	defer db.treeMutex.RUnlock()
+
+	return db.tree.Query(path, query)
+}
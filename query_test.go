@@ -0,0 +1,83 @@
+package liquiddb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func queryKeys(children []EventData) []string {
+	keys := make([]string, len(children))
+	for i, child := range children {
+		keys[i] = child.Key
+	}
+
+	return keys
+}
+
+var messages = map[string]interface{}{
+	"chat": map[string]interface{}{
+		"room1": map[string]interface{}{
+			"m1": map[string]interface{}{"timestamp": 30, "text": "c"},
+			"m2": map[string]interface{}{"timestamp": 10, "text": "a"},
+			"m3": map[string]interface{}{"timestamp": 20.5, "text": "b"},
+			"m4": map[string]interface{}{"text": "no timestamp"},
+		},
+	},
+}
+
+var room = []string{"chat", "room1"}
+
+func TestQuery_OrderByChild(t *testing.T) {
+	db := New()
+	db.Set(messages)
+
+	res, err := db.Query(room, Query{OrderBy: QueryOrderByChild, Child: []string{"timestamp"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keys := queryKeys(res); !reflect.DeepEqual(keys, []string{"m4", "m2", "m3", "m1"}) {
+		t.Fatalf("Invalid order %+v", keys)
+	}
+
+	res, _ = db.Query(room, Query{OrderBy: QueryOrderByChild, Child: []string{"timestamp"}, LimitToLast: 2})
+	if keys := queryKeys(res); !reflect.DeepEqual(keys, []string{"m3", "m1"}) {
+		t.Fatalf("Invalid limitToLast %+v", keys)
+	}
+
+	res, _ = db.Query(room, Query{OrderBy: QueryOrderByChild, Child: []string{"timestamp"}, StartAt: 15, EndAt: 30.0})
+	if keys := queryKeys(res); !reflect.DeepEqual(keys, []string{"m3", "m1"}) {
+		t.Fatalf("Invalid range %+v", keys)
+	}
+
+	res, _ = db.Query(room, Query{OrderBy: QueryOrderByChild, Child: []string{"text"}, EqualTo: "a"})
+	if keys := queryKeys(res); !reflect.DeepEqual(keys, []string{"m2"}) {
+		t.Fatalf("Invalid equalTo %+v", keys)
+	}
+}
+
+func TestQuery_OrderByValueAndKey(t *testing.T) {
+	db := New()
+	db.Set(map[string]interface{}{
+		"scores": map[string]interface{}{
+			"a": 3,
+			"b": "text",
+			"c": true,
+			"d": 1.5,
+		},
+	})
+
+	res, _ := db.Query([]string{"scores"}, Query{OrderBy: QueryOrderByValue, LimitToFirst: 3})
+	if keys := queryKeys(res); !reflect.DeepEqual(keys, []string{"c", "d", "a"}) {
+		t.Fatalf("Invalid value order %+v", keys)
+	}
+
+	res, _ = db.Query([]string{"scores"}, Query{StartAt: "b", EndAt: "c"})
+	if keys := queryKeys(res); !reflect.DeepEqual(keys, []string{"b", "c"}) {
+		t.Fatalf("Invalid key range %+v", keys)
+	}
+
+	if _, err := db.Query([]string{"scores"}, Query{LimitToFirst: 1, LimitToLast: 1}); err != ErrInvalidQuery {
+		t.Fatalf("Invalid error %v", err)
+	}
+}
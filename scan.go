@@ -0,0 +1,65 @@
+package liquiddb
+
+import (
+	"sort"
+)
+
+//ScanResult is a page of children returned by Scan
+type ScanResult struct {
+	Children []EventData `json:"children"`
+	//More is true when there are children after the returned ones,
+	//Next is the key to pass as startKey to get them
+	More bool   `json:"more"`
+	Next string `json:"next,omitempty"`
+}
+
+//Scan returns the children of the node at path ordered by their keys, integer keys come
+//first in numeric order, followed by the rest in lexicographic order. startKey is inclusive,
+//endKey is exclusive, empty keys and a limit of 0 mean unbounded.
+func (t *tree) Scan(path Path, startKey, endKey string, limit int) (ScanResult, error) {
+	node := t.findNode(path, false)
+	if node == nil {
+		return ScanResult{}, ErrNotFound
+	}
+
+	keys := node.ChildKeys()
+	from := 0
+	if startKey != "" {
+		from = sort.Search(len(keys), func(i int) bool {
+			return compareKeys(keys[i], startKey) >= 0
+		})
+	}
+
+	res := ScanResult{
+		Children: make([]EventData, 0),
+	}
+
+	for _, key := range keys[from:] {
+		if endKey != "" && compareKeys(key, endKey) >= 0 {
+			break
+		}
+
+		if limit > 0 && len(res.Children) == limit {
+			res.More = true
+			res.Next = key
+			break
+		}
+
+		child, ok := node.child(key)
+		if !ok {
+			continue
+		}
+
+		res.Children = append(res.Children, t.queryResult(child))
+	}
+
+	return res, nil
+}
+
+//Scan returns a page of the children of the node at path in key order, see ScanResult for paging
+func (db LiquidDb) Scan(path Path, startKey, endKey string, limit int) (ScanResult, error) {
+	db.treeMutex.RLock()
+	defer db.treeMutex.RUnlock()
+
+	return db.tree.Scan(path, startKey, endKey, limit)
+}
@@ -0,0 +1,92 @@
+package liquiddb
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func scanKeys(res ScanResult) []string {
+	keys := make([]string, len(res.Children))
+	for i, child := range res.Children {
+		keys[i] = child.Key
+	}
+
+	return keys
+}
+
+func TestScan_Order(t *testing.T) {
+	db := New()
+	db.Set(map[string]interface{}{
+		"users": map[string]interface{}{
+			"b":  1,
+			"10": 2,
+			"a":  3,
+			"2":  4,
+		},
+	})
+
+	res, err := db.Scan([]string{"users"}, "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keys := scanKeys(res); !reflect.DeepEqual(keys, []string{"2", "10", "a", "b"}) {
+		t.Fatalf("Invalid order %+v", keys)
+	}
+
+	if res.More || res.Children[0].Value != 4 {
+		t.Fatalf("Invalid result %+v", res)
+	}
+
+	res, _ = db.Scan([]string{"users"}, "10", "b", 0)
+	if keys := scanKeys(res); !reflect.DeepEqual(keys, []string{"10", "a"}) {
+		t.Fatalf("Invalid range %+v", keys)
+	}
+}
+
+func TestScan_Paging(t *testing.T) {
+	db := New()
+
+	users := map[string]interface{}{}
+	for i := 0; i < 25; i++ {
+		users[strconv.Itoa(i)] = i
+	}
+	db.SetPath([]string{"users"}, users)
+
+	var keys []string
+	startKey := ""
+	for {
+		res, err := db.Scan([]string{"users"}, startKey, "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		keys = append(keys, scanKeys(res)...)
+		if !res.More {
+			break
+		}
+
+		startKey = res.Next
+	}
+
+	if len(keys) != 25 {
+		t.Fatalf("Invalid amount of keys %d", len(keys))
+	}
+
+	for i, key := range keys {
+		if key != strconv.Itoa(i) {
+			t.Fatalf("Invalid key %s at %d", key, i)
+		}
+	}
+
+	db.Delete([]string{"users", "3"})
+	res, _ := db.Scan([]string{"users"}, "2", "", 2)
+	if keys := scanKeys(res); !reflect.DeepEqual(keys, []string{"2", "4"}) {
+		t.Fatalf("Deleted key still scanned %+v", keys)
+	}
+
+	if _, err := db.Scan([]string{"missing"}, "", "", 0); err != ErrNotFound {
+		t.Fatalf("Invalid error %v", err)
+	}
+}
@@ -0,0 +1,609 @@
+package liquiddb
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-errors/errors"
+)
+
+var (
+	//ErrInvalidSchema is returned when a schema document uses a keyword wrongly
+	ErrInvalidSchema = errors.New("Invalid schema")
+	//ErrSchemaNotFound is returned when no schema is attached to a pattern
+	ErrSchemaNotFound = errors.New("Schema not found")
+)
+
+//SchemaViolation is a single part of a value that does not match its schema
+type SchemaViolation struct {
+	Path    Path   `json:"path,omitempty"`
+	Keyword string `json:"keyword,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+//SchemaError is returned when a write would leave a value that does not match the schema of its pattern,
+//nothing of the write is applied
+type SchemaError struct {
+	Path       Path
+	Pattern    Path
+	Violations []SchemaViolation
+}
+
+func (e *SchemaError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, fmt.Sprintf("%s %s", v.Path, v.Message))
+	}
+
+	return fmt.Sprintf("The value at %s does not match the schema of %s, %s",
+		e.Path, e.Pattern, strings.Join(messages, ", "))
+}
+
+//schema is a compiled JSON Schema document, it supports the type, enum, const, required, properties,
+//additionalProperties, items, minItems, maxItems, minLength, maxLength, pattern, minimum and maximum keywords.
+//Other keywords are ignored.
+type schema struct {
+	never bool
+
+	types    []string
+	enum     []interface{}
+	constant []interface{}
+
+	required             []string
+	properties           map[string]*schema
+	additionalProperties *schema
+
+	items    *schema
+	minItems *int
+	maxItems *int
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	minimum *float64
+	maximum *float64
+}
+
+var schemaTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true,
+	"number": true, "integer": true, "string": true,
+}
+
+func schemaInt(v interface{}) (*int, error) {
+	f, ok := toFloat(v)
+	if !ok || f < 0 || f != math.Trunc(f) {
+		return nil, ErrInvalidSchema
+	}
+
+	i := int(f)
+	return &i, nil
+}
+
+func schemaStrings(v interface{}) ([]string, error) {
+	if s, ok := v.(string); ok {
+		return []string{s}, nil
+	}
+
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, ErrInvalidSchema
+	}
+
+	res := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, ErrInvalidSchema
+		}
+
+		res = append(res, s)
+	}
+
+	return res, nil
+}
+
+//compileSchema compiles a schema document, booleans are the schemas that accept everything or nothing
+func compileSchema(document interface{}) (*schema, error) {
+	if b, ok := document.(bool); ok {
+		return &schema{never: !b}, nil
+	}
+
+	doc, ok := document.(map[string]interface{})
+	if !ok {
+		return nil, ErrInvalidSchema
+	}
+
+	s := &schema{}
+	var err error
+
+	if v, ok := doc["type"]; ok {
+		if s.types, err = schemaStrings(v); err != nil {
+			return nil, err
+		}
+
+		for _, t := range s.types {
+			if !schemaTypes[t] {
+				return nil, ErrInvalidSchema
+			}
+		}
+	}
+
+	if v, ok := doc["enum"]; ok {
+		if s.enum, ok = v.([]interface{}); !ok {
+			return nil, ErrInvalidSchema
+		}
+	}
+
+	if v, ok := doc["const"]; ok {
+		s.constant = []interface{}{v}
+	}
+
+	if v, ok := doc["required"]; ok {
+		if _, isString := v.(string); isString {
+			return nil, ErrInvalidSchema
+		}
+
+		if s.required, err = schemaStrings(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if v, ok := doc["properties"]; ok {
+		properties, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, ErrInvalidSchema
+		}
+
+		s.properties = make(map[string]*schema, len(properties))
+		for key, property := range properties {
+			if s.properties[key], err = compileSchema(property); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if v, ok := doc["additionalProperties"]; ok {
+		if s.additionalProperties, err = compileSchema(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if v, ok := doc["items"]; ok {
+		if s.items, err = compileSchema(v); err != nil {
+			return nil, err
+		}
+	}
+
+	for keyword, target := range map[string]**int{
+		"minItems":  &s.minItems,
+		"maxItems":  &s.maxItems,
+		"minLength": &s.minLength,
+		"maxLength": &s.maxLength,
+	} {
+		if v, ok := doc[keyword]; ok {
+			if *target, err = schemaInt(v); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if v, ok := doc["pattern"]; ok {
+		expr, ok := v.(string)
+		if !ok {
+			return nil, ErrInvalidSchema
+		}
+
+		if s.pattern, err = regexp.Compile(expr); err != nil {
+			return nil, ErrInvalidSchema
+		}
+	}
+
+	for keyword, target := range map[string]**float64{
+		"minimum": &s.minimum,
+		"maximum": &s.maximum,
+	} {
+		if v, ok := doc[keyword]; ok {
+			f, ok := toFloat(v)
+			if !ok {
+				return nil, ErrInvalidSchema
+			}
+
+			*target = &f
+		}
+	}
+
+	return s, nil
+}
+
+//schemaType returns the JSON Schema type of a value of the tree
+func schemaType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+
+	if valueRank(value) == 2 {
+		return "number"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
+
+func (s *schema) hasType(value interface{}) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+
+	actual := schemaType(value)
+	for _, t := range s.types {
+		if t == actual {
+			return true
+		}
+
+		if t == "integer" && actual == "number" {
+			f, _ := toFloat(value)
+			if f == math.Trunc(f) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//validate returns the violations of value, path is the path of value in the tree
+func (s *schema) validate(path Path, value interface{}) []SchemaViolation {
+	violations := make([]SchemaViolation, 0)
+	violate := func(keyword, format string, args ...interface{}) {
+		violations = append(violations, SchemaViolation{
+			Path:    path,
+			Keyword: keyword,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if s.never {
+		violate("false", "is not allowed")
+		return violations
+	}
+
+	if !s.hasType(value) {
+		violate("type", "must be of type %s, it is %s", strings.Join(s.types, " or "), schemaType(value))
+		return violations
+	}
+
+	if s.enum != nil {
+		found := false
+		for _, v := range s.enum {
+			found = found || jsonEqual(v, value)
+		}
+
+		if !found {
+			violate("enum", "must be one of %v", s.enum)
+		}
+	}
+
+	if len(s.constant) > 0 && !jsonEqual(s.constant[0], value) {
+		violate("const", "must be %v", s.constant[0])
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range s.required {
+			if _, ok := v[key]; !ok {
+				violate("required", "must have %s", key)
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			child := s.properties[key]
+			if child == nil {
+				child = s.additionalProperties
+			}
+
+			if child != nil {
+				violations = append(violations, child.validate(appendPath(path, key), v[key])...)
+			}
+		}
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			violate("minItems", "must have at least %d items", *s.minItems)
+		}
+
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			violate("maxItems", "must have at most %d items", *s.maxItems)
+		}
+
+		if s.items != nil {
+			for i, item := range v {
+				violations = append(violations, s.items.validate(appendPath(path, fmt.Sprint(i)), item)...)
+			}
+		}
+	case string:
+		length := len([]rune(v))
+		if s.minLength != nil && length < *s.minLength {
+			violate("minLength", "must be at least %d characters long", *s.minLength)
+		}
+
+		if s.maxLength != nil && length > *s.maxLength {
+			violate("maxLength", "must be at most %d characters long", *s.maxLength)
+		}
+
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			violate("pattern", "must match %s", s.pattern)
+		}
+	default:
+		if f, ok := toFloat(v); ok && valueRank(v) == 2 {
+			if s.minimum != nil && f < *s.minimum {
+				violate("minimum", "must be at least %v", *s.minimum)
+			}
+
+			if s.maximum != nil && f > *s.maximum {
+				violate("maximum", "must be at most %v", *s.maximum)
+			}
+		}
+	}
+
+	return violations
+}
+
+//schemaSet holds the schemas attached to path patterns, it is guarded by the lock of the tree
+type schemaSet struct {
+	patterns map[string]Path
+	schemas  map[string]*schema
+}
+
+func newSchemaSet() *schemaSet {
+	return &schemaSet{
+		patterns: make(map[string]Path),
+		schemas:  make(map[string]*schema),
+	}
+}
+
+func (s *schemaSet) empty() bool {
+	return len(s.schemas) == 0
+}
+
+//matchedAncestor returns the shortest ancestor of path matched by a pattern, its whole value
+//has to be validated when a write below it changes something
+func (s *schemaSet) matchedAncestor(path Path) (Path, bool) {
+	path = path.Relative()
+	for i := 1; i < len(path); i++ {
+		for _, pattern := range s.patterns {
+			if _, ok := MatchPath(pattern, path[:i]); ok {
+				return path[:i], true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+//units returns the paths of the committed values a record needs to be validated, none of them inside another.
+//These are the matched ancestors of the paths it writes and the paths it changes in place, the values
+//it sets are given by the record itself so nothing below them has to be copied.
+func (s *schemaSet) units(record logRecord) []Path {
+	var written, changed []Path
+	switch record.Operation {
+	case logOperationBatch:
+		units := make([]Path, 0)
+		for _, r := range record.Records {
+			units = append(units, s.units(r)...)
+		}
+
+		return outermostPaths(units)
+	case logOperationSet:
+		written = leafPaths(Path{}, record.Value)
+	case logOperationUpdate:
+		written = leafPaths(record.Path.Relative(), record.Value)
+	case logOperationSetPath, logOperationDelete:
+		written = []Path{record.Path.Scope()}
+	default:
+		changed = []Path{record.Path.Scope()}
+	}
+
+	units := make([]Path, 0)
+	for _, path := range append(written, changed...) {
+		if ancestor, ok := s.matchedAncestor(path); ok {
+			units = append(units, ancestor)
+		}
+	}
+
+	return outermostPaths(append(units, changed...))
+}
+
+//leafPaths returns the paths of the values inside the objects of value, below prefix
+func leafPaths(prefix Path, value interface{}) []Path {
+	object, ok := value.(map[string]interface{})
+	if !ok || len(object) == 0 {
+		return []Path{prefix}
+	}
+
+	paths := make([]Path, 0, len(object))
+	for key, child := range object {
+		paths = append(paths, leafPaths(appendPath(prefix, key), child)...)
+	}
+
+	return paths
+}
+
+//outermostPaths returns the paths that are not inside one of the others, each once
+func outermostPaths(paths []Path) []Path {
+	sort.SliceStable(paths, func(i, j int) bool {
+		return len(paths[i]) < len(paths[j])
+	})
+
+	kept := make(map[string]bool)
+	res := make([]Path, 0, len(paths))
+	for _, path := range paths {
+		covered := false
+		for i := 0; i <= len(path) && !covered; i++ {
+			covered = kept[path[:i].String()]
+		}
+
+		if !covered {
+			kept[path.String()] = true
+			res = append(res, path)
+		}
+	}
+
+	return res
+}
+
+//check validates every value matched by a schema that the events of a write touched in view
+func (s *schemaSet) check(view *tree, events []EventData) error {
+	keys := make([]string, 0, len(s.patterns))
+	for key := range s.patterns {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		pattern := s.patterns[key]
+		for _, m := range view.findMatches(pattern) {
+			if !touched(m.node.Path, events) {
+				continue
+			}
+
+			//the value of an empty object is nil, it is validated as one
+			value := view.jsonValue(m.node)
+			if value == nil && !m.node.IsArray() {
+				value = map[string]interface{}{}
+			}
+
+			if violations := s.schemas[key].validate(m.node.Path, value); len(violations) > 0 {
+				return &SchemaError{Path: m.node.Path, Pattern: pattern, Violations: violations}
+			}
+		}
+	}
+
+	return nil
+}
+
+//touched returns whether one of the events changed the subtree at path
+func touched(path Path, events []EventData) bool {
+	for _, ev := range events {
+		if hasPathPrefix(ev.Path, path) || hasPathPrefix(path, ev.Path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//recordScope returns the path below which a record writes, the root for a record that can write anywhere
+func recordScope(record logRecord) Path {
+	switch record.Operation {
+	case logOperationSet:
+		return Path{}
+	case logOperationBatch:
+		var scope Path
+		for i, r := range record.Records {
+			if i == 0 {
+				scope = recordScope(r)
+				continue
+			}
+
+			other := recordScope(r)
+			n := 0
+			for n < len(scope) && n < len(other) && scope[n] == other[n] {
+				n++
+			}
+
+			scope = scope[:n]
+		}
+
+		return scope
+	}
+
+	return record.Path.Scope()
+}
+
+//checkSchemas applies the record to a copy of the values matched by the schemas it can change and validates
+//the result, the caller must hold the writeMutex so the data cannot change before the record is applied
+func (db LiquidDb) checkSchemas(record logRecord) error {
+	//expiring and evicting data are not writes of a client, they are never held back
+	if record.Operation == logOperationExpire || record.Reason != "" {
+		return nil
+	}
+
+	db.treeMutex.RLock()
+	schemas := db.tree.schemas
+	if schemas.empty() {
+		db.treeMutex.RUnlock()
+		return nil
+	}
+	units := schemas.units(record)
+	db.treeMutex.RUnlock()
+
+	view := db.viewOf(units)
+	events, err := record.apply(view)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	db.treeMutex.RLock()
+	defer db.treeMutex.RUnlock()
+
+	return schemas.check(view, events)
+}
+
+//AddSchema attaches a JSON Schema document to a path pattern, every later write
+//is rejected with a SchemaError when it leaves a matched value that does not match the schema.
+//Attaching a schema to a pattern replaces the previous one, existing data is not checked.
+func (db LiquidDb) AddSchema(pattern Path, document map[string]interface{}) error {
+	if len(pattern.Relative()) == 0 {
+		return ErrInvalidSchema
+	}
+
+	s, err := compileSchema(document)
+	if err != nil {
+		return err
+	}
+
+	//a write sees the same schemas while it is checked and while its scope is computed
+	db.writeMutex.Lock()
+	defer db.writeMutex.Unlock()
+	db.treeMutex.Lock()
+	defer db.treeMutex.Unlock()
+
+	key := pattern.Relative().String()
+	db.tree.schemas.patterns[key] = pattern.Relative()
+	db.tree.schemas.schemas[key] = s
+	return nil
+}
+
+//RemoveSchema detaches the schema of a path pattern
+func (db LiquidDb) RemoveSchema(pattern Path) error {
+	db.writeMutex.Lock()
+	defer db.writeMutex.Unlock()
+	db.treeMutex.Lock()
+	defer db.treeMutex.Unlock()
+
+	key := pattern.Relative().String()
+	if _, ok := db.tree.schemas.schemas[key]; !ok {
+		return ErrSchemaNotFound
+	}
+
+	delete(db.tree.schemas.patterns, key)
+	delete(db.tree.schemas.schemas, key)
+	return nil
+}
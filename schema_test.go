@@ -0,0 +1,158 @@
+package liquiddb
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+var userSchema = `{
+	"type": "object",
+	"required": ["email"],
+	"properties": {
+		"email": {"type": "string", "pattern": "@"},
+		"age": {"type": "integer", "minimum": 0},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2}
+	}
+}`
+
+func addUserSchema(t *testing.T, db *LiquidDb) {
+	var document map[string]interface{}
+	if err := json.Unmarshal([]byte(userSchema), &document); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AddSchema(Path{"users", "{id}"}, document); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchema_SetPath(t *testing.T) {
+	db := New()
+	addUserSchema(t, db)
+
+	if _, err := db.SetPath(Path{"users", "u1"}, map[string]interface{}{"email": "a@b", "age": 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := db.SetPath(Path{"users", "u1", "email"}, "invalid")
+	schemaErr, ok := err.(*SchemaError)
+	if !ok || !reflect.DeepEqual(schemaErr.Path, Path{"users", "u1"}) ||
+		len(schemaErr.Violations) != 1 || schemaErr.Violations[0].Keyword != "pattern" {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if _, err := db.SetPath(Path{"users", "u2"}, map[string]interface{}{"age": 1.5}); err == nil {
+		t.Fatal("Accepted a user without an email")
+	}
+
+	if v, _ := db.Get(Path{"users", "u1", "email"}); v.Value != "a@b" {
+		t.Fatalf("Rejected write changed the value %+v", v.Value)
+	}
+
+	if v, _ := db.Get(Path{"users", "u2"}); v.Value != nil {
+		t.Fatalf("Rejected write was applied %+v", v.Value)
+	}
+}
+
+func TestSchema_SetAndDelete(t *testing.T) {
+	db := New()
+	addUserSchema(t, db)
+
+	_, err := db.Set(map[string]interface{}{
+		"users": map[string]interface{}{"u1": map[string]interface{}{"email": "a@b", "tags": []interface{}{"x", 1}}},
+	})
+	schemaErr, ok := err.(*SchemaError)
+	if !ok || !reflect.DeepEqual(schemaErr.Violations[0].Path, Path{"users", "u1", "tags", "1"}) {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	db.SetPath(Path{"users", "u1"}, map[string]interface{}{"email": "a@b"})
+	if _, ok := db.Delete(Path{"users", "u1", "email"}); ok {
+		t.Fatal("Deleted a required value")
+	}
+
+	if _, ok := db.Delete(Path{"users", "u1"}); !ok {
+		t.Fatal("Could not delete the whole user")
+	}
+}
+
+func TestSchema_TryDelete(t *testing.T) {
+	db := New()
+	addUserSchema(t, db)
+
+	db.SetPath(Path{"users", "u1"}, map[string]interface{}{"email": "a@b"})
+	if _, err := db.TryDelete(Path{"users", "u1", "email"}); err == nil {
+		t.Fatal("Deleted a required value")
+	} else if _, ok := err.(*SchemaError); !ok {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if _, err := db.TryDelete(Path{"users", "u2"}); err != ErrNotFound {
+		t.Fatalf("Invalid error %v", err)
+	}
+}
+
+func TestSchema_Units(t *testing.T) {
+	db := New()
+	addUserSchema(t, db)
+
+	units := db.tree.schemas.units(logRecord{Operation: logOperationBatch, Records: []logRecord{
+		{Operation: logOperationSetPath, Path: Path{"users", "u1", "email"}, Value: "a@b"},
+		{Operation: logOperationUpdate, Path: Path{"users"}, Value: map[string]interface{}{
+			"u1": map[string]interface{}{"age": 3},
+			"u2": map[string]interface{}{"email": "c@d"},
+		}},
+		{Operation: logOperationSetPath, Path: Path{"posts", "p1"}, Value: "x"},
+		{Operation: logOperationIncrement, Path: Path{"counts", "c1"}, Value: 1},
+	}})
+
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].String() < units[j].String()
+	})
+	expected := []Path{{"counts", "c1"}, {"users", "u1"}, {"users", "u2"}}
+	if !reflect.DeepEqual(units, expected) {
+		t.Fatalf("Invalid units %v", units)
+	}
+}
+
+func TestSchema_Patch(t *testing.T) {
+	db := New()
+	addUserSchema(t, db)
+	db.SetPath(Path{"users", "u1"}, map[string]interface{}{"email": "a@b"})
+
+	_, err := db.ApplyPatch(Path{"users", "u1"}, patchDocument(t, `[
+		{"op": "add", "path": "/age", "value": 1},
+		{"op": "add", "path": "/age", "value": -1}
+	]`))
+	if _, ok := err.(*SchemaError); !ok {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if err := db.RemoveSchema(Path{"users", "{id}"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.SetPath(Path{"users", "u1", "age"}, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.RemoveSchema(Path{"users", "{id}"}); err != ErrSchemaNotFound {
+		t.Fatalf("Invalid error %v", err)
+	}
+}
+
+func TestSchema_Invalid(t *testing.T) {
+	db := New()
+	for _, document := range []map[string]interface{}{
+		{"type": "text"},
+		{"required": "email"},
+		{"minLength": -1},
+		{"pattern": "("},
+	} {
+		if err := db.AddSchema(Path{"users"}, document); err != ErrInvalidSchema {
+			t.Fatalf("Invalid error %v for %+v", err, document)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package liquiddb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+var (
+	//ErrInvalidSnapshot is returned when a snapshot file is not recognized or is corrupted
+	ErrInvalidSnapshot = errors.New("Invalid snapshot")
+
+	snapshotMagic = []byte("LIQUIDB1")
+)
+
+//snapshotNode is a node as it is stored in a snapshot, values are gob encoded
+//so they keep their exact types instead of being flattened like json would do
+type snapshotNode struct {
+	Key      string
+	Value    interface{}
+	Version  uint64
+	Array    bool
+	Expiry   time.Time
+	Children []snapshotNode
+}
+
+type snapshot struct {
+	Timestamp time.Time
+	Revision  uint64
+	Sequence  uint64
+	Root      snapshotNode
+}
+
+func captureNode(node *Node) snapshotNode {
+	s := snapshotNode{
+		Key:     node.Key,
+		Value:   node.GetValue(),
+		Version: node.GetVersion(),
+		Array:   node.IsArray(),
+		Expiry:  node.GetExpiry(),
+	}
+
+	for _, child := range node.childNodes() {
+		s.Children = append(s.Children, captureNode(child))
+	}
+
+	return s
+}
+
+//restoreNode creates the node of s with its descendants as a child of the node at parentPath
+func (t *tree) restoreNode(s snapshotNode, parentPath Path) *Node {
+	node := newNode(s.Key, parentPath, t.owner)
+	for _, child := range s.Children {
+		restored := t.restoreNode(child, node.Path)
+		node.children = node.children.set(restored.Key, restored)
+		node.size += restored.size
+	}
+
+	if !s.Expiry.IsZero() {
+		node.expiry = s.Expiry
+		t.expiring[node.Path.String()] = node.Path
+	}
+
+	node.value = s.Value
+	node.size += valueSize(s.Value)
+	node.version = s.Version
+	node.array = s.Array
+	node.pristine = false
+
+	return node
+}
+
+//Snapshot writes the whole database to a single file at path. The data is captured
+//atomically in regard to the other writes, the file is written after that without
+//blocking them. An existing file is replaced only after the new one is fully written.
+func (db LiquidDb) Snapshot(path string) error {
+	//the published root never changes, only taking it together with its sequence waits for the writers
+	db.treeMutex.RLock()
+	view := db.ReadView()
+	sequence := db.changes.current()
+	db.treeMutex.RUnlock()
+
+	s := snapshot{
+		Timestamp: time.Now().UTC(),
+		Revision:  view.Revision(),
+		Sequence:  sequence,
+		Root:      captureNode(view.root.root),
+	}
+
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(s); err != nil {
+		return err
+	}
+
+	header := make([]byte, len(snapshotMagic)+4)
+	copy(header, snapshotMagic)
+	binary.BigEndian.PutUint32(header[len(snapshotMagic):], crc32.Checksum(payload.Bytes(), logChecksumTable))
+
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	fail := func(err error) error {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+
+	if _, err := file.Write(header); err != nil {
+		return fail(err)
+	}
+
+	if _, err := payload.WriteTo(file); err != nil {
+		return fail(err)
+	}
+
+	if err := file.Sync(); err != nil {
+		return fail(err)
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+func readSnapshot(path string) (snapshot, error) {
+	var s snapshot
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+
+	headerSize := len(snapshotMagic) + 4
+	if len(content) < headerSize || !bytes.Equal(content[:len(snapshotMagic)], snapshotMagic) {
+		return s, ErrInvalidSnapshot
+	}
+
+	payload := content[headerSize:]
+	checksum := binary.BigEndian.Uint32(content[len(snapshotMagic):headerSize])
+	if crc32.Checksum(payload, logChecksumTable) != checksum {
+		return s, ErrInvalidSnapshot
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&s); err != nil {
+		return s, ErrInvalidSnapshot
+	}
+
+	return s, nil
+}
+
+//NewFromSnapshot creates new database instance holding the data of the snapshot at path
+func NewFromSnapshot(path string) (*LiquidDb, error) {
+	s, err := readSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	db := newLiquidDb()
+	db.tree.root = db.tree.restoreNode(s.Root, nil)
+	*db.tree.revision = s.Revision
+	db.changes.sequence = s.Sequence
+	db.tree.publish()
+
+	db.startReaper(NewConfigBuilder().Finalize().ReapInterval)
+	return db, nil
+}
@@ -0,0 +1,131 @@
+package liquiddb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func tempSnapshotPath(t *testing.T) (string, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "liquiddb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return filepath.Join(dir, "liquid.snapshot"), func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSnapshot_Restore(t *testing.T) {
+	path, cleanup := tempSnapshotPath(t)
+	defer cleanup()
+
+	j := map[string]interface{}{
+		"bytes": []byte("foobar"),
+		"numbers": map[string]interface{}{
+			"int":     5,
+			"int64":   int64(6),
+			"float32": float32(1.5),
+			"float64": 2.5,
+		},
+		"list": []interface{}{"a", 1, map[string]interface{}{"b": true}},
+	}
+
+	db := New()
+	db.Set(j)
+	if err := db.Snapshot(path); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := NewFromSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, _ := restored.Get([]string{})
+	if !reflect.DeepEqual(v.Value, j) {
+		t.Fatalf("Invalid restored value %+v", v.Value)
+	}
+
+	ops, _ := restored.SetPath([]string{"numbers", "int"}, 6)
+	if ops[0].Operation != EventOperationUpdate {
+		t.Fatalf("Restored node is not marked as existing %+v", ops[0])
+	}
+}
+
+func TestSnapshot_ConsistentWhileWriting(t *testing.T) {
+	path, cleanup := tempSnapshotPath(t)
+	defer cleanup()
+
+	db := New()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(branch string) {
+			defer wg.Done()
+
+			for n := 0; ; n++ {
+				select {
+				case <-stop:
+					return
+				default:
+					db.SetPath([]string{branch}, map[string]interface{}{"x": n, "y": n})
+				}
+			}
+		}(fmt.Sprintf("branch%d", i))
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := db.Snapshot(path); err != nil {
+			t.Fatal(err)
+		}
+
+		restored, err := NewFromSnapshot(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v, _ := restored.Get([]string{})
+		for branch, value := range v.Value.(map[string]interface{}) {
+			pair := value.(map[string]interface{})
+			if pair["x"] != pair["y"] {
+				t.Fatalf("Inconsistent snapshot of %s %+v", branch, pair)
+			}
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+}
+
+func TestSnapshot_Invalid(t *testing.T) {
+	path, cleanup := tempSnapshotPath(t)
+	defer cleanup()
+
+	db := New()
+	db.Set(data)
+	if err := db.Snapshot(path); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content[len(content)-1] ^= 0xff
+	ioutil.WriteFile(path, content, 0644)
+
+	if _, err := NewFromSnapshot(path); err != ErrInvalidSnapshot {
+		t.Fatalf("Invalid error %v", err)
+	}
+}
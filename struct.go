@@ -0,0 +1,95 @@
+package liquiddb
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+//fromJSON decodes json into the values the tree holds, integers stay int64
+//so that they keep their precision, the other numbers become float64
+func fromJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return convertNumbers(value), nil
+}
+
+func convertNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = convertNumbers(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = convertNumbers(child)
+		}
+	}
+
+	return value
+}
+
+//structValue converts v to the value the tree holds for it the way encoding/json marshals it,
+//so json tags, omitempty, embedded structs, time values and custom marshalers are honored
+func structValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromJSON(data)
+}
+
+//SetStruct sets the value at path to v, which is marshaled like encoding/json does. The fields
+//of structs become nodes that can be read and subscribed to by their own paths, the fields left
+//out by omitempty are deleted.
+func (db LiquidDb) SetStruct(path Path, v interface{}) ([]EventData, error) {
+	value, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.SetPath(path, value)
+}
+
+//GetInto gets the value at path and unmarshals it into v like encoding/json does,
+//ErrNotFound is returned when there is no value at path
+func (db LiquidDb) GetInto(path Path, v interface{}) error {
+	ev, err := db.Get(path)
+	if err != nil {
+		return err
+	}
+
+	if ev.Version == 0 {
+		return ErrNotFound
+	}
+
+	data, err := json.Marshal(ev.Value)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+//SetStruct stages setting the value at path to v, see LiquidDb.SetStruct
+func (tx *Tx) SetStruct(path Path, v interface{}) error {
+	value, err := structValue(v)
+	if err != nil {
+		return err
+	}
+
+	return tx.SetPath(path, value)
+}
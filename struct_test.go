@@ -0,0 +1,76 @@
+package liquiddb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type upperName string
+
+func (n upperName) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + strings.ToUpper(string(n)) + `"`), nil
+}
+
+type audit struct {
+	Created time.Time `json:"created"`
+}
+
+type structUser struct {
+	audit
+	Name     upperName         `json:"name"`
+	Email    string            `json:"email,omitempty"`
+	Age      int64             `json:"age"`
+	Tags     []string          `json:"tags"`
+	Settings map[string]string `json:"settings"`
+	Ignored  string            `json:"-"`
+}
+
+func TestStruct_SetGet(t *testing.T) {
+	db := New()
+	created := time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+	user := structUser{
+		audit:    audit{Created: created},
+		Name:     "ana",
+		Email:    "a@b",
+		Age:      1 << 60,
+		Tags:     []string{"x"},
+		Settings: map[string]string{"theme": "dark"},
+		Ignored:  "x",
+	}
+
+	path := Path{"users", "u1"}
+	if _, err := db.SetStruct(path, user); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := db.Get(Path{"users", "u1", "settings", "theme"}); v.Value != "dark" {
+		t.Fatalf("Invalid nested field %+v", v.Value)
+	}
+
+	if v, _ := db.Get(Path{"users", "u1", "created"}); v.Value != created.Format(time.RFC3339) {
+		t.Fatalf("Invalid embedded field %+v", v.Value)
+	}
+
+	var res structUser
+	if err := db.GetInto(path, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	user.Name = "ANA"
+	user.Ignored = ""
+	if !reflect.DeepEqual(res, user) {
+		t.Fatalf("Invalid struct %+v", res)
+	}
+
+	user.Email = ""
+	db.SetStruct(path, user)
+	if v, _ := db.Get(Path{"users", "u1", "email"}); v.Value != nil {
+		t.Fatalf("Omitted field was not deleted %+v", v.Value)
+	}
+
+	if err := db.GetInto(Path{"users", "u2"}, &res); err != ErrNotFound {
+		t.Fatalf("Invalid error %v", err)
+	}
+}
@@ -0,0 +1,270 @@
+package liquiddb
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-errors/errors"
+)
+
+var (
+	//ErrInvalidSubscriberOptions is returned when a queue size is negative or an overflow policy is unknown
+	ErrInvalidSubscriberOptions = errors.New("Invalid subscriber options")
+	//ErrSubscriberNotFound is returned for a channel which is not subscribed to anything
+	ErrSubscriberNotFound = errors.New("Subscriber not found")
+	//ErrSubscriberDisconnected is returned when subscribing a channel closed by OverflowDisconnect before StopNotify is called for it
+	ErrSubscriberDisconnected = errors.New("Subscriber disconnected")
+)
+
+//OverflowPolicy controls what happens to an event when the queue of its subscriber is full
+type OverflowPolicy string
+
+const (
+	//OverflowBlock waits for the subscriber to make room, holding back the events of everyone else
+	OverflowBlock = OverflowPolicy("block")
+	//OverflowDropOldest drops the oldest queued event to make room
+	OverflowDropOldest = OverflowPolicy("dropOldest")
+	//OverflowDropNewest drops the event
+	OverflowDropNewest = OverflowPolicy("dropNewest")
+	//OverflowDisconnect drops all the subscriptions of the channel and closes it
+	OverflowDisconnect = OverflowPolicy("disconnect")
+)
+
+const defaultQueueSize = 64
+
+//SubscriberOptions are the delivery options of a channel, the zero value is a queue of the default size with OverflowBlock
+type SubscriberOptions struct {
+	QueueSize int
+	Overflow  OverflowPolicy
+	//ChangeSets sends a single EventOperationChangeSet event per mutation instead of its events,
+	//it holds the events the channel is subscribed to with one event per changed path
+	ChangeSets bool
+}
+
+func (o SubscriberOptions) validate() (SubscriberOptions, error) {
+	if o.QueueSize < 0 {
+		return o, ErrInvalidSubscriberOptions
+	}
+
+	if o.QueueSize == 0 {
+		o.QueueSize = defaultQueueSize
+	}
+
+	switch o.Overflow {
+	case "":
+		o.Overflow = OverflowBlock
+	case OverflowBlock, OverflowDropOldest, OverflowDropNewest, OverflowDisconnect:
+	default:
+		return o, ErrInvalidSubscriberOptions
+	}
+
+	return o, nil
+}
+
+//SubscriberStats are the delivery counters of a channel
+type SubscriberStats struct {
+	Queued    int
+	Delivered uint64
+	Dropped   uint64
+}
+
+//subscriber queues the events of a channel and sends them to it from its own goroutine,
+//so a slow channel only holds back its own events unless it uses OverflowBlock
+type subscriber struct {
+	//the counters are first to be aligned for the atomic operations
+	delivered uint64
+	dropped   uint64
+
+	c       chan<- EventData
+	options SubscriberOptions
+	//prefixes holds the prefixes the channel is subscribed to keyed by their string form,
+	//the notifier lock guards them
+	prefixes map[string]Path
+
+	mutex        sync.Mutex
+	cond         *sync.Cond
+	queue        []EventData
+	sending      bool
+	stopped      bool
+	disconnected bool
+	//closing closes the channel once the queue is empty
+	closing bool
+	//done is closed when the subscriber is stopped or disconnected to abort a pending send
+	done chan struct{}
+	//onDisconnect is called before the channel is closed by OverflowDisconnect
+	onDisconnect func(s *subscriber)
+}
+
+func newSubscriber(c chan<- EventData, options SubscriberOptions, onDisconnect func(s *subscriber)) *subscriber {
+	s := &subscriber{
+		c:            c,
+		options:      options,
+		prefixes:     make(map[string]Path),
+		done:         make(chan struct{}),
+		onDisconnect: onDisconnect,
+	}
+
+	s.cond = sync.NewCond(&s.mutex)
+	go s.deliverLoop()
+
+	return s
+}
+
+//enqueue queues ev applying the overflow policy
+func (s *subscriber) enqueue(ev EventData) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for len(s.queue) >= s.options.QueueSize && s.options.Overflow == OverflowBlock && !s.isDone() {
+		s.cond.Wait()
+	}
+
+	if s.isDone() {
+		return
+	}
+
+	if len(s.queue) >= s.options.QueueSize {
+		switch s.options.Overflow {
+		case OverflowDropOldest:
+			//the queue can be longer than its size when the options were changed
+			n := len(s.queue) - s.options.QueueSize + 1
+			s.queue = s.queue[n:]
+			atomic.AddUint64(&s.dropped, uint64(n))
+		case OverflowDropNewest:
+			atomic.AddUint64(&s.dropped, 1)
+			return
+		case OverflowDisconnect:
+			atomic.AddUint64(&s.dropped, uint64(len(s.queue)+1))
+			s.disconnected = true
+			s.finish()
+			return
+		}
+	}
+
+	//nothing is ahead of ev so it can skip the queue when the channel has room
+	if len(s.queue) == 0 && !s.sending {
+		select {
+		case s.c <- ev:
+			atomic.AddUint64(&s.delivered, 1)
+			return
+		default:
+		}
+	}
+
+	s.queue = append(s.queue, ev)
+	s.cond.Broadcast()
+}
+
+//enqueueEvents queues the events of a notification, grouped into ChangeSets when they are enabled
+func (s *subscriber) enqueueEvents(events []EventData) {
+	s.mutex.Lock()
+	batched := s.options.ChangeSets
+	s.mutex.Unlock()
+
+	if batched {
+		events = changeSets(events)
+	}
+
+	for _, ev := range events {
+		s.enqueue(ev)
+	}
+}
+
+//replay queues retained events regardless of the size of the queue and the overflow policy
+func (s *subscriber) replay(events []EventData) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.options.ChangeSets {
+		events = changeSets(events)
+	}
+
+	if !s.isDone() && len(events) > 0 {
+		s.queue = append(s.queue, events...)
+		s.cond.Broadcast()
+	}
+}
+
+//stop discards the queued events and ends the delivery without closing the channel
+func (s *subscriber) stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.isDone() {
+		s.stopped = true
+		s.finish()
+	}
+}
+
+//close closes the channel once the queued events are sent to it
+func (s *subscriber) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closing = true
+	s.cond.Broadcast()
+}
+
+//isDone must be called holding the subscriber lock
+func (s *subscriber) isDone() bool {
+	return s.stopped || s.disconnected
+}
+
+//finish must be called holding the subscriber lock
+func (s *subscriber) finish() {
+	s.queue = nil
+	close(s.done)
+	s.cond.Broadcast()
+}
+
+func (s *subscriber) deliverLoop() {
+	for {
+		s.mutex.Lock()
+		for len(s.queue) == 0 && !s.isDone() && !s.closing {
+			s.cond.Wait()
+		}
+
+		if s.isDone() || len(s.queue) == 0 {
+			disconnected, closing := s.disconnected, s.closing && !s.stopped
+			s.mutex.Unlock()
+
+			if disconnected {
+				s.onDisconnect(s)
+			}
+
+			if disconnected || closing {
+				close(s.c)
+			}
+
+			return
+		}
+
+		ev := s.queue[0]
+		s.queue = s.queue[1:]
+		s.sending = true
+		s.cond.Broadcast()
+		s.mutex.Unlock()
+
+		select {
+		case s.c <- ev:
+			atomic.AddUint64(&s.delivered, 1)
+		case <-s.done:
+		}
+
+		s.mutex.Lock()
+		s.sending = false
+		s.mutex.Unlock()
+	}
+}
+
+func (s *subscriber) stats() SubscriberStats {
+	s.mutex.Lock()
+	queued := len(s.queue)
+	s.mutex.Unlock()
+
+	return SubscriberStats{
+		Queued:    queued,
+		Delivered: atomic.LoadUint64(&s.delivered),
+		Dropped:   atomic.LoadUint64(&s.dropped),
+	}
+}
@@ -0,0 +1,130 @@
+package liquiddb
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSubscriber_SlowSubscriber(t *testing.T) {
+	db := New()
+	slow := make(chan EventData)
+	fast := make(chan EventData, 100)
+	db.NotifyPathWithOptions(slow, Path{}, SubscriberOptions{QueueSize: 2, Overflow: OverflowDropNewest}, EventOperationInsert)
+	db.Notify(fast, EventOperationInsert)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 50; i++ {
+			db.SetPath(Path{strconv.Itoa(i)}, i)
+		}
+
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("A slow subscriber blocked the writes")
+	}
+
+	for i := 0; i < 50; i++ {
+		if ev := <-fast; ev.Value != i {
+			t.Fatalf("Invalid order %v at %d", ev.Value, i)
+		}
+	}
+
+	if stats, _ := db.SubscriberStats(slow); stats.Dropped == 0 || stats.Queued > 2 {
+		t.Fatalf("Invalid stats %+v", stats)
+	}
+}
+
+func TestSubscriber_DropOldest(t *testing.T) {
+	db := New()
+	ch := make(chan EventData)
+	db.NotifyPathWithOptions(ch, Path{}, SubscriberOptions{QueueSize: 3, Overflow: OverflowDropOldest}, EventOperationInsert)
+
+	for i := 0; i < 20; i++ {
+		db.SetPath(Path{strconv.Itoa(i)}, i)
+	}
+
+	//wait for the notify loop to queue everything
+	for {
+		if stats, _ := db.SubscriberStats(ch); stats.Queued+int(stats.Dropped) >= 19 {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	//one of the first events is held by the delivery goroutine, the newest are queued
+	values := []interface{}{(<-ch).Value, (<-ch).Value, (<-ch).Value, (<-ch).Value}
+	if values[0].(int) >= 17 || values[1] != 17 || values[2] != 18 || values[3] != 19 {
+		t.Fatalf("Invalid events %v", values)
+	}
+}
+
+func TestSubscriber_Disconnect(t *testing.T) {
+	db := New()
+	ch := make(chan EventData)
+	db.NotifyPathWithOptions(ch, Path{}, SubscriberOptions{QueueSize: 1, Overflow: OverflowDisconnect}, EventOperationInsert)
+
+	for i := 0; i < 5; i++ {
+		db.SetPath(Path{strconv.Itoa(i)}, i)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if ok {
+				continue
+			}
+		case <-timeout:
+			t.Fatal("The channel was not closed")
+		}
+
+		break
+	}
+
+	if err := db.Notify(ch, EventOperationInsert); err != ErrSubscriberDisconnected {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if _, err := db.SubscriberStats(ch); err != ErrSubscriberNotFound {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	db.StopNotify(ch)
+	if err := db.Notify(make(chan EventData), EventOperationInsert); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.NotifyPathWithOptions(ch, Path{}, SubscriberOptions{Overflow: "foo"}); err != ErrInvalidSubscriberOptions {
+		t.Fatalf("Invalid error %v", err)
+	}
+}
+
+func TestSubscriber_StopBlocked(t *testing.T) {
+	db := New()
+	ch := make(chan EventData)
+	db.NotifyPathWithOptions(ch, Path{}, SubscriberOptions{QueueSize: 1}, EventOperationInsert)
+
+	for i := 0; i < 5; i++ {
+		db.SetPath(Path{strconv.Itoa(i)}, i)
+	}
+
+	db.StopNotify(ch)
+
+	done := make(chan struct{})
+	go func() {
+		db.SetPath(Path{"after"}, 1)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("A stopped subscriber kept blocking the writes")
+	}
+}
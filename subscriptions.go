@@ -0,0 +1,157 @@
+package liquiddb
+
+//subscription counts the subscriptions of a channel to an operation at a path. The events of the
+//mutations after from are sent to the channel, the ones up to since were replayed when it subscribed.
+type subscription struct {
+	count int
+	from  uint64
+	since uint64
+}
+
+//subscriptionNode is a node of the subscription trie, it holds the subscriptions of the channels to the path leading to it
+type subscriptionNode struct {
+	children    map[string]*subscriptionNode
+	subscribers map[chan<- EventData]map[EventOperation]*subscription
+}
+
+func newSubscriptionNode() *subscriptionNode {
+	return &subscriptionNode{
+		children:    make(map[string]*subscriptionNode),
+		subscribers: make(map[chan<- EventData]map[EventOperation]*subscription),
+	}
+}
+
+func (s *subscriptionNode) empty() bool {
+	return len(s.children) == 0 && len(s.subscribers) == 0
+}
+
+//add subscribes c to the operations of the events under prefix, see subscription
+func (s *subscriptionNode) add(prefix Path, c chan<- EventData, operations []EventOperation, from, since uint64) {
+	node := s
+	for _, key := range prefix {
+		child, ok := node.children[key]
+		if !ok {
+			child = newSubscriptionNode()
+			node.children[key] = child
+		}
+
+		node = child
+	}
+
+	ops, ok := node.subscribers[c]
+	if !ok {
+		ops = make(map[EventOperation]*subscription)
+		node.subscribers[c] = ops
+	}
+
+	for _, op := range operations {
+		sub, ok := ops[op]
+		if !ok {
+			ops[op] = &subscription{count: 1, from: from, since: since}
+			continue
+		}
+
+		sub.count++
+		if from < sub.from {
+			sub.from = from
+		}
+
+		if since < sub.since {
+			sub.since = since
+		}
+	}
+}
+
+//remove drops a subscription of c to each of the operations under prefix, all of them when no operations are given.
+//It returns whether c is still subscribed to anything under prefix, the nodes left empty are removed.
+func (s *subscriptionNode) remove(prefix Path, c chan<- EventData, operations []EventOperation) bool {
+	if len(prefix) > 0 {
+		child, ok := s.children[prefix[0]]
+		if !ok {
+			return false
+		}
+
+		subscribed := child.remove(prefix[1:], c, operations)
+		if child.empty() {
+			delete(s.children, prefix[0])
+		}
+
+		return subscribed
+	}
+
+	ops, ok := s.subscribers[c]
+	if !ok {
+		return false
+	}
+
+	if len(operations) == 0 {
+		delete(s.subscribers, c)
+		return false
+	}
+
+	for _, op := range operations {
+		if sub, ok := ops[op]; ok {
+			if sub.count--; sub.count <= 0 {
+				delete(ops, op)
+			}
+		}
+	}
+
+	if len(ops) == 0 {
+		delete(s.subscribers, c)
+		return false
+	}
+
+	return true
+}
+
+//match returns the channels to send the event of op at path to, each of them once. The events of the mutations
+//are sent only to the subscriptions made before the mutations were committed, seq is 0 for the other events.
+//Only the nodes along path are visited so the cost does not depend on the other subscriptions.
+func (s *subscriptionNode) match(path Path, op EventOperation, seq uint64) []chan<- EventData {
+	var channels []chan<- EventData
+	var seen map[chan<- EventData]bool
+
+	node := s
+	for i := 0; node != nil; i++ {
+		for c, ops := range node.subscribers {
+			sub, ok := ops[op]
+			if !ok || (seq != 0 && seq <= sub.since) || seen[c] {
+				continue
+			}
+
+			if seen == nil {
+				seen = make(map[chan<- EventData]bool)
+			}
+
+			seen[c] = true
+			channels = append(channels, c)
+		}
+
+		if i == len(path) {
+			break
+		}
+
+		node = node.children[path[i]]
+	}
+
+	return channels
+}
+
+//covered returns whether c has been or will be sent the event of op at path of the mutation seq
+func (s *subscriptionNode) covered(c chan<- EventData, path Path, op EventOperation, seq uint64) bool {
+	node := s
+	for i := 0; node != nil; i++ {
+		if sub, ok := node.subscribers[c][op]; ok && seq > sub.from {
+			return true
+		}
+
+		if i == len(path) {
+			break
+		}
+
+		node = node.children[path[i]]
+	}
+
+	return false
+}
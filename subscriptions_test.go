@@ -0,0 +1,80 @@
+package liquiddb
+
+import (
+	"testing"
+	"time"
+)
+
+func receivePaths(ch chan EventData) []string {
+	paths := make([]string, 0)
+	for {
+		select {
+		case ev := <-ch:
+			paths = append(paths, ev.Path.Relative().String())
+		case <-time.After(50 * time.Millisecond):
+			return paths
+		}
+	}
+}
+
+func TestSubscriptions_NotifyPath(t *testing.T) {
+	db := New()
+	users := make(chan EventData, 100)
+	orders := make(chan EventData, 100)
+	db.NotifyPath(users, Path{TreeRoot, "users", "u1"}, EventOperationInsert, EventOperationUpdate)
+	db.NotifyPath(orders, Path{"orders"}, EventOperationInsert)
+
+	db.SetPath(Path{"users", "u1", "name"}, "a")
+	db.SetPath(Path{"users", "u2", "name"}, "b")
+	db.SetPath(Path{"users", "u1", "name"}, "c")
+
+	paths := receivePaths(users)
+	expected := []string{"users.u1.name", "users.u1.name"}
+	if len(paths) != len(expected) {
+		t.Fatalf("Invalid events %v", paths)
+	}
+
+	for i := range expected {
+		if paths[i] != expected[i] {
+			t.Fatalf("Invalid events %v", paths)
+		}
+	}
+
+	if paths := receivePaths(orders); len(paths) != 0 {
+		t.Fatalf("Events outside of the prefix %v", paths)
+	}
+
+	if err := db.NotifyPath(users, Path{"users", "*"}, EventOperationInsert); err == nil {
+		t.Fatal("Subscribed to a pattern")
+	}
+}
+
+func TestSubscriptions_StopNotifyPath(t *testing.T) {
+	db := New()
+	ch := make(chan EventData, 100)
+	db.NotifyPath(ch, Path{"users"}, EventOperationInsert)
+	db.NotifyPath(ch, Path{"users"}, EventOperationInsert)
+	db.NotifyPath(ch, Path{"users", "u1"}, EventOperationInsert)
+
+	db.SetPath(Path{"users", "u1"}, 1)
+	if paths := receivePaths(ch); len(paths) != 1 {
+		t.Fatalf("Overlapping subscriptions sent duplicates %v", paths)
+	}
+
+	db.StopNotifyPath(ch, Path{"users"}, EventOperationInsert)
+	db.StopNotifyPath(ch, Path{"users", "u1"}, EventOperationInsert)
+	db.SetPath(Path{"users", "u2"}, 1)
+	if paths := receivePaths(ch); len(paths) != 1 {
+		t.Fatalf("Subscription removed too early %v", paths)
+	}
+
+	db.StopNotify(ch)
+	db.SetPath(Path{"users", "u3"}, 1)
+	if paths := receivePaths(ch); len(paths) != 0 {
+		t.Fatalf("Events after StopNotify %v", paths)
+	}
+
+	if !db.subscriptions.empty() || len(db.subscribers) != 0 {
+		t.Fatal("Subscriptions were not cleaned up")
+	}
+}
@@ -0,0 +1,195 @@
+package liquiddb
+
+//Tx stages reads and writes of a transaction, the staged writes are visible
+//only to the transaction itself until it is committed
+type Tx struct {
+	db      LiquidDb
+	records []logRecord
+
+	//overlay holds a copy of the committed data under scope with the staged records applied to it.
+	//It is kept up to date as records are staged and copied again only when scope has to grow.
+	overlay *tree
+	scope   Path
+
+	//err is the error of the first write which could not be staged, it fails the commit
+	err error
+}
+
+//Transaction runs fn in a transaction. The writes staged by fn are committed
+//atomically when it returns nil and discarded when it returns an error.
+//Other writers wait for the transaction to finish, so fn must not call the
+//write methods of the database directly, only the ones of tx.
+//The committed events are published as a single ordered batch after the commit.
+//A staged write which fails, an invalid array index for example, fails the whole transaction
+//with its error even when fn ignores it.
+func (db LiquidDb) Transaction(fn func(tx *Tx) error) ([]EventData, error) {
+	op, turn, err := db.transaction(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.publishInTurn(op, turn), nil
+}
+
+//transaction commits the writes of fn, the events it returns without an error have to be published in their turn
+func (db LiquidDb) transaction(fn func(tx *Tx) error) ([]EventData, publishTurn, error) {
+	db.writeMutex.Lock()
+	defer db.writeMutex.Unlock()
+
+	if db.lifecycle.isClosed() {
+		return nil, publishTurn{}, ErrClosed
+	}
+
+	op, err := db.commitTransaction(fn)
+	if err != nil {
+		return nil, publishTurn{}, err
+	}
+
+	return op, db.lifecycle.committed(), nil
+}
+
+func (db LiquidDb) commitTransaction(fn func(tx *Tx) error) ([]EventData, error) {
+	tx := &Tx{db: db}
+	if err := fn(tx); err != nil {
+		return nil, err
+	}
+
+	if tx.err != nil {
+		return nil, tx.err
+	}
+
+	if len(tx.records) == 0 {
+		return []EventData{}, nil
+	}
+
+	return db.apply(logRecord{
+		Operation: logOperationBatch,
+		Records:   tx.records,
+	})
+}
+
+//Set stages the insertion of a json
+func (tx *Tx) Set(data map[string]interface{}) error {
+	return tx.stage(logRecord{
+		Operation: logOperationSet,
+		Value:     data,
+	})
+}
+
+//SetPath stages setting a value by a path
+func (tx *Tx) SetPath(path Path, data interface{}) error {
+	return tx.stage(logRecord{
+		Operation: logOperationSetPath,
+		Path:      path,
+		Value:     data,
+	})
+}
+
+//stage adds the record to the transaction if it is valid
+func (tx *Tx) stage(record logRecord) error {
+	err := record.validate()
+	if err == nil {
+		err = tx.stageValid(record)
+	}
+
+	if err != nil && tx.err == nil {
+		tx.err = err
+	}
+
+	return err
+}
+
+//stageValid applies a validated record to the overlay and adds it to the transaction, a record
+//which cannot be applied to the data the staged ones leave is rejected so the commit cannot fail
+func (tx *Tx) stageValid(record logRecord) error {
+	overlay := tx.view(recordScope(record))
+	if err := record.check(overlay); err != nil {
+		return err
+	}
+
+	if _, err := record.apply(overlay); err != nil {
+		//the overlay can be half changed, it is copied again with the staged records
+		tx.overlay = nil
+		return err
+	}
+
+	tx.records = append(tx.records, record)
+	return nil
+}
+
+//widen grows the scope of the overlay to cover path, it returns whether the scope changed
+func (tx *Tx) widen(path Path) bool {
+	if tx.scope == nil {
+		tx.scope = append(Path{}, path...)
+		return true
+	}
+
+	n := 0
+	for n < len(tx.scope) && n < len(path) && tx.scope[n] == path[n] {
+		n++
+	}
+
+	if n == len(tx.scope) {
+		return false
+	}
+
+	tx.scope = tx.scope[:n]
+	return true
+}
+
+//Delete stages the deletion of a path, it returns false if the path does not exist
+func (tx *Tx) Delete(path Path) bool {
+	err := tx.stageValid(logRecord{
+		Operation: logOperationDelete,
+		Path:      path,
+	})
+
+	return err == nil
+}
+
+//Get gets a value by a path as it would be if the transaction was committed now
+func (tx *Tx) Get(path Path) (EventData, error) {
+	return tx.view(path).Get(path)
+}
+
+//view returns the overlay holding the committed data under path with the staged writes applied
+//on top of it, it must not be changed since it is shared by the reads of the transaction
+func (tx *Tx) view(path Path) *tree {
+	if tx.widen(path.Scope()) || tx.overlay == nil {
+		tx.overlay = tx.db.view(tx.scope)
+		for _, record := range tx.records {
+			record.apply(tx.overlay)
+		}
+	}
+
+	return tx.overlay
+}
+
+//view creates a private tree holding the committed data under path
+func (db LiquidDb) view(path Path) *tree {
+	return db.viewOf([]Path{path})
+}
+
+//viewOf creates a private tree holding the committed data under paths, none of them can be inside another.
+//The subtrees are shared with the published root, the view copies the nodes it changes like the writes do.
+func (db LiquidDb) viewOf(paths []Path) *tree {
+	view := newTree()
+	published := db.ReadView()
+	committed := published.tree()
+
+	*view.revision = published.Revision()
+	for _, path := range paths {
+		node := committed.findNode(path, false)
+		if node == nil {
+			continue
+		}
+
+		if node == committed.root {
+			view.root = node
+		} else {
+			view.addChild(view.findNode(node.Path[:len(node.Path)-1], true), node)
+		}
+	}
+
+	return view
+}
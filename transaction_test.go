@@ -0,0 +1,176 @@
+package liquiddb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+func TestTransaction_Commit(t *testing.T) {
+	db := New()
+
+	ops, err := db.Transaction(func(tx *Tx) error {
+		tx.Set(data)
+		tx.SetPath([]string{"foo", "baz"}, 5)
+
+		v, _ := tx.Get([]string{"foo"})
+		if !reflect.DeepEqual(v.Value, map[string]interface{}{"bar": b, "baz": 5}) {
+			t.Fatalf("Staged writes not visible in transaction %+v", v.Value)
+		}
+
+		outside, _ := db.Get([]string{"foo"})
+		if outside.Value != nil {
+			t.Fatalf("Staged writes visible outside of transaction %+v", outside.Value)
+		}
+
+		if !tx.Delete([]string{"foo", "bar"}) {
+			t.Fatal("Could not delete staged value")
+		}
+
+		if tx.Delete([]string{"missing"}) {
+			t.Fatal("Deleted missing value")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last := ops[len(ops)-1]
+	if last.Operation != EventOperationDelete || last.Key != "bar" {
+		t.Fatalf("Invalid last event %+v", last)
+	}
+
+	v, _ := db.Get([]string{"foo"})
+	if !reflect.DeepEqual(v.Value, map[string]interface{}{"baz": 5}) {
+		t.Fatalf("Invalid committed value %+v", v.Value)
+	}
+}
+
+func TestTransaction_Rollback(t *testing.T) {
+	db := New()
+	db.Set(data)
+
+	rollback := errors.New("rollback")
+	_, err := db.Transaction(func(tx *Tx) error {
+		tx.SetPath(p, "changed")
+		tx.Delete([]string{"foo"})
+		return rollback
+	})
+	if err != rollback {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	v, _ := db.Get(p)
+	if !reflect.DeepEqual(v.Value, b) {
+		t.Fatalf("Rolled back transaction changed value %+v", v.Value)
+	}
+}
+
+func TestTransaction_NotifyBatch(t *testing.T) {
+	db := New()
+
+	ch := make(chan EventData, 10)
+	db.Notify(ch, EventOperationInsert)
+
+	db.Transaction(func(tx *Tx) error {
+		tx.SetPath([]string{"a"}, 1)
+		tx.SetPath([]string{"b"}, 2)
+		tx.SetPath([]string{"c"}, 3)
+		return nil
+	})
+
+	for _, key := range []string{"a", "b", "c"} {
+		select {
+		case ev := <-ch:
+			if ev.Key != key {
+				t.Fatalf("Invalid event order, expected %s got %+v", key, ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Missing event for %s", key)
+		}
+	}
+}
+
+func TestTransaction_Replay(t *testing.T) {
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+
+	db := openPersistent(t, path)
+	db.Set(data)
+	db.Transaction(func(tx *Tx) error {
+		tx.Delete(p)
+		tx.SetPath([]string{"foo", "baz"}, 5)
+		return nil
+	})
+	db.Close()
+
+	db = openPersistent(t, path)
+	defer db.Close()
+
+	v, _ := db.Get([]string{"foo"})
+	if !reflect.DeepEqual(v.Value, map[string]interface{}{"baz": 5}) {
+		t.Fatalf("Invalid replayed transaction %+v", v.Value)
+	}
+}
+
+func TestTransaction_Overlay(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	db.SetPath(Path{"a", "x"}, 1)
+	db.SetPath(Path{"b"}, 1)
+
+	db.Transaction(func(tx *Tx) error {
+		tx.SetPath(Path{"a", "y"}, 2)
+		if v, _ := tx.Get(Path{"a"}); !reflect.DeepEqual(v.Value, map[string]interface{}{"x": 1, "y": 2}) {
+			t.Fatalf("Invalid staged value %+v", v.Value)
+		}
+
+		//the reads and writes inside the scope reuse the overlay
+		overlay := tx.overlay
+		tx.SetPath(Path{"a", "z"}, 3)
+		tx.Delete(Path{"a", "y"})
+		if v, _ := tx.Get(Path{"a"}); !reflect.DeepEqual(v.Value, map[string]interface{}{"x": 1, "z": 3}) || tx.overlay != overlay {
+			t.Fatalf("Invalid staged value %+v", v.Value)
+		}
+
+		//a write outside of it widens the scope
+		tx.Delete(Path{"b"})
+		if v, _ := tx.Get(Path{TreeRoot}); !reflect.DeepEqual(v.Value, map[string]interface{}{"a": map[string]interface{}{"x": 1, "z": 3}}) {
+			t.Fatalf("Invalid staged value %+v", v.Value)
+		}
+
+		return nil
+	})
+}
+
+func TestTransaction_FailedWrite(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	db.SetPath(list, []interface{}{1})
+	for _, key := range []string{"b", "9"} {
+		_, err := db.Transaction(func(tx *Tx) error {
+			tx.SetPath(Path{"a"}, 1)
+			tx.SetPath(Path{"list", key}, 2)
+			return nil
+		})
+		if err == nil {
+			t.Fatalf("Committed a write to list.%s", key)
+		}
+
+		//an unrelated write publishes the tree again
+		db.SetPath(Path{"other"}, key)
+		if v, _ := db.Get(Path{"a"}); v.Value != nil {
+			t.Fatalf("Failed transaction left %+v", v.Value)
+		}
+	}
+
+	if v, _ := db.Get(list); !reflect.DeepEqual(v.Value, []interface{}{1}) {
+		t.Fatalf("Failed transaction changed the array %+v", v.Value)
+	}
+}
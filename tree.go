@@ -1,7 +1,10 @@
 package liquiddb
 
 import (
-	"sync"
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-errors/errors"
 )
@@ -14,92 +17,344 @@ const (
 var (
 	//ErrNotFound is returned when the requested path by Get is not found
 	ErrNotFound = errors.New("Not found")
+	//ErrNotArray is returned when a list operation is performed on a path that is not an array
+	ErrNotArray = errors.New("Not an array")
+	//ErrIndexOutOfRange is returned when a list operation refers to a missing element
+	ErrIndexOutOfRange = errors.New("Index out of range")
+	//ErrInvalidIndex is returned when a key of an array element is not an index
+	ErrInvalidIndex = errors.New("Invalid array index")
+)
+
+type normalizedKind uint8
+
+const (
+	normalizedValue normalizedKind = iota
+	//the containers are normalized to a marker before their children
+	//so the node can be turned into an object or array before they are set
+	normalizedObject
+	normalizedArray
 )
 
 type normalizedData struct {
 	key   []string
 	value interface{}
+
+	kind normalizedKind
+	//length is the amount of elements of a normalizedArray
+	length int
+}
+
+func appendPath(path Path, key string) Path {
+	res := make(Path, len(path)+1)
+	copy(res, path)
+	res[len(path)] = key
+
+	return res
 }
 
 type tree struct {
 	root *Node
+
+	//owner is the edit the writes since the last publish belong to, the nodes of the published
+	//roots belong to earlier edits so a write copies them before it changes them
+	owner uint64
+
+	//revision is incremented by every write, the nodes changed by a write get its revision as version
+	revision *uint64
+
+	//clock is incremented by every access, the accessed nodes get its tick
+	clock *uint64
+
+	//published holds the publishedRoot of the last write
+	published *atomic.Value
+
+	indexes *indexSet
+
+	//expiring holds the paths of the nodes that have a TTL, the paths of removed nodes are dropped from it by the reaper
+	expiring map[string]Path
+
+	//histories keeps the recent values of the subtrees with an enabled history
+	histories *historySet
+
+	//schemas are the JSON Schemas the writes are checked against
+	schemas *schemaSet
+}
+
+//owners is the last edit given to a tree
+var owners uint64
+
+func nextOwner() uint64 {
+	return atomic.AddUint64(&owners, 1)
 }
 
 func newTree() *tree {
-	return &tree{
-		root: newNode(TreeRoot, nil),
+	owner := nextOwner()
+	t := &tree{
+		root:      newNode(TreeRoot, nil, owner),
+		owner:     owner,
+		revision:  new(uint64),
+		clock:     new(uint64),
+		indexes:   newIndexSet(),
+		expiring:  make(map[string]Path),
+		histories: newHistorySet(),
+		schemas:   newSchemaSet(),
+		published: &atomic.Value{},
 	}
+
+	t.publish()
+	return t
 }
 
-func (t tree) normalize(data map[string]interface{}, relative []string) ([]normalizedData, error) {
-	res := make([]normalizedData, 0)
+func (t *tree) nextRevision() uint64 {
+	return atomic.AddUint64(t.revision, 1)
+}
+
+func (t *tree) currentRevision() uint64 {
+	return atomic.LoadUint64(t.revision)
+}
+
+//own returns the node when it belongs to the edit of the tree, otherwise a copy of it that does
+func (t *tree) own(node *Node) *Node {
+	if node.owner == t.owner {
+		return node
+	}
+
+	owned := *node
+	owned.owner = t.owner
+	return &owned
+}
 
-	var n func(interface{}, []string) error
-	n = func(value interface{}, path []string) error {
-		switch v := value.(type) {
-		case map[string]interface{}:
-			for k, v := range v {
-				n(v, append(path, k))
+//mutablePath returns the nodes from the root to path, the ones that do not belong to the edit of the tree
+//are replaced by their copies first. The missing nodes are created when create is true, otherwise
+//nil is returned when one of them is missing.
+func (t *tree) mutablePath(path Path, create bool) []*Node {
+	path = path.Relative()
+	t.root = t.own(t.root)
+
+	nodes := make([]*Node, 1, len(path)+1)
+	nodes[0] = t.root
+	for _, key := range path {
+		node := nodes[len(nodes)-1]
+		child, ok := node.child(key)
+		switch {
+		case ok && child.owner != t.owner:
+			child = t.own(child)
+			node.children = node.children.set(key, child)
+		case !ok && !create:
+			return nil
+		case !ok:
+			//a node with children has no value of its own
+			child = newNode(key, node.Path, t.owner)
+			delta := child.size - valueSize(node.value)
+			node.value = nil
+			node.children = node.children.set(key, child)
+			for _, n := range nodes {
+				n.size += delta
 			}
-		default:
-			//[]byte, string, int, int16, int32, int64, int8, float32, float64, bool, []interface{}
-			res = append(res, normalizedData{path, v})
 		}
 
+		nodes = append(nodes, child)
+	}
+
+	return nodes
+}
+
+//mutable returns the node at path that can be changed in place, see mutablePath
+func (t *tree) mutable(path Path, create bool) *Node {
+	nodes := t.mutablePath(path, create)
+	if nodes == nil {
 		return nil
 	}
 
-	for k, v := range data {
-		if err := n(v, append(relative, k)); err != nil {
-			return nil, err
-		}
+	return nodes[len(nodes)-1]
+}
+
+//addSize adds delta to the size of the node at path and of its ancestors
+func (t *tree) addSize(path Path, delta int64) {
+	if delta == 0 {
+		return
 	}
 
-	return res, nil
+	for _, node := range t.mutablePath(path, false) {
+		node.size += delta
+	}
 }
 
-func (t tree) findNode(path []string, autoCreate bool) *Node {
-	if len(path) == 1 && path[0] == TreeRoot {
-		return t.root
+//setAncestorsVersion sets the version of the node at path and of all of its ancestors
+func (t *tree) setAncestorsVersion(path Path, v uint64) {
+	for _, node := range t.mutablePath(path, false) {
+		node.version = v
 	}
+}
 
-	node := t.root
-	if len(path) > 0 && path[0] == TreeRoot {
-		path = path[1:]
+//setValue replaces the value of a node returned by mutable
+func (t *tree) setValue(node *Node, v interface{}) {
+	delta := valueSize(v) - valueSize(node.value)
+	node.value = v
+	t.addSize(node.Path, delta)
+}
+
+//addChild adds child to a node returned by mutable, replacing its child with the same key
+func (t *tree) addChild(parent *Node, child *Node) {
+	delta := child.size
+	if existing, ok := parent.child(child.Key); ok {
+		delta -= existing.size
+	}
+
+	parent.children = parent.children.set(child.Key, child)
+	t.addSize(parent.Path, delta)
+}
+
+//removeChild removes the child with key of a node returned by mutable
+func (t *tree) removeChild(parent *Node, key string) {
+	child, ok := parent.child(key)
+	if !ok {
+		return
+	}
+
+	parent.children = parent.children.remove(key)
+	t.addSize(parent.Path, -child.size)
+}
+
+//rekey returns the node with key as a child of the node at parentPath, the node must not be one of the children
+//of its parent. The paths of its descendants change with it, so they are copied too.
+func (t *tree) rekey(node *Node, key string, parentPath Path) *Node {
+	moved := t.own(node)
+	moved.size += int64(len(key) - len(moved.Key))
+	moved.Key = key
+	moved.Path = childPath(parentPath, key)
+	if !moved.expiry.IsZero() {
+		t.expiring[moved.Path.String()] = moved.Path
+	}
+
+	var children *pmap
+	moved.children.each(func(child *Node) {
+		children = children.set(child.Key, t.rekey(child, child.Key, moved.Path))
+	})
+	moved.children = children
+
+	return moved
+}
+
+//parent returns the parent of the node, nil for the root
+func (t *tree) parent(node *Node) *Node {
+	if node.Key == TreeRoot {
+		return nil
+	}
+
+	return t.findNode(node.Path[:len(node.Path)-1], false)
+}
+
+//index returns the position of the node in its parent, nil if the parent is not an array
+func (t *tree) index(node *Node) *int {
+	return elementIndex(t.parent(node), node)
+}
+
+//elementIndex returns the position of the node in parent, nil if parent is not an array
+func elementIndex(parent, node *Node) *int {
+	if parent == nil || !parent.array {
+		return nil
 	}
 
-	for {
-		if len(path) == 0 {
-			break
+	i, err := strconv.Atoi(node.Key)
+	if err != nil {
+		return nil
+	}
+
+	return &i
+}
+
+func (t *tree) normalize(data map[string]interface{}, relative Path) ([]normalizedData, error) {
+	res := make([]normalizedData, 0)
+
+	for k, v := range data {
+		res = t.normalizeValue(res, v, appendPath(relative, k))
+	}
+
+	return res, nil
+}
+
+func (t *tree) normalizeValue(res []normalizedData, value interface{}, path Path) []normalizedData {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		res = append(res, normalizedData{key: path, kind: normalizedObject})
+		for k, v := range v {
+			res = t.normalizeValue(res, v, appendPath(path, k))
+		}
+	case []interface{}:
+		res = append(res, normalizedData{key: path, kind: normalizedArray, length: len(v)})
+		for i, v := range v {
+			res = t.normalizeValue(res, v, appendPath(path, strconv.Itoa(i)))
 		}
+	default:
+		//[]byte, string, int, int16, int32, int64, int8, float32, float64, bool
+		res = append(res, normalizedData{key: path, value: v})
+	}
 
-		key := path[0]
-		path = path[1:]
-		if _, ok := node.Children.Get(key); !ok {
-			if autoCreate {
-				newNode(key, node)
-			} else {
-				return nil
-			}
+	return res
+}
+
+//findNode returns the node at path, with autoCreate the node and its missing ancestors are created
+//and it can be changed in place like the nodes returned by mutable
+func (t *tree) findNode(path Path, autoCreate bool) *Node {
+	if autoCreate {
+		return t.mutable(path, true)
+	}
+
+	node := t.root
+	for _, key := range path.Relative() {
+		child, ok := node.child(key)
+		if !ok {
+			return nil
 		}
 
-		n, _ := node.Children.Get(key)
-		node = n.(*Node)
+		node = child
 	}
 
 	return node
 }
 
-func (t tree) performOnNodes(data []normalizedData) []EventData {
+func (t *tree) performOnNodes(data []normalizedData) []EventData {
 	ops := make([]EventData, 0) //TODO: optimize
+	revision := t.nextRevision()
+	t.mutable(Path{}, false).version = revision
 
 	for _, d := range data {
+		switch d.kind {
+		case normalizedObject:
+			//objects are merged, only an array has to be emptied before it becomes an object
+			node := t.findNode(d.key, false)
+			if node != nil && node.IsArray() {
+				ops = append(ops, t.deleteChildren(node, func(string) bool { return true })...)
+				t.mutable(d.key, false).array = false
+			}
+
+			continue
+		case normalizedArray:
+			//arrays are replaced, so everything that is not one of the new indexes is deleted
+			node := t.findNode(d.key, true)
+			ops = append(ops, t.deleteChildren(node, func(key string) bool {
+				i, err := strconv.Atoi(key)
+				return err != nil || i < 0 || i >= d.length || strconv.Itoa(i) != key
+			})...)
+			node.array = true
+			t.setValue(node, nil)
+
+			//the elements will notify about the array, an empty one has to do it by itself
+			if d.length > 0 {
+				continue
+			}
+		}
+
 		for i := range d.key {
 			node := t.findNode(d.key[:i+1], true)
+			oldValue := node.GetValue()
 
-			if node.Key == d.key[len(d.key)-1] {
-				node.SetValue(d.value)
+			if i == len(d.key)-1 && d.kind == normalizedValue {
+				t.setValue(node, d.value)
+				node.array = false
 			}
+			node.version = revision
 
 			var op EventOperation
 			if node.GetPristine() && node.Key != TreeRoot {
@@ -113,18 +368,64 @@ func (t tree) performOnNodes(data []normalizedData) []EventData {
 				Operation: op,
 				Path:      node.Path,
 				Value:     node.GetValue(),
+				Version:   revision,
+				Index:     t.index(node),
+			}
+
+			if op == EventOperationUpdate {
+				info.OldValue = oldValue
 			}
 
 			ops = append(ops, info)
 
-			node.SetPristine(false)
+			node.pristine = false
+		}
+	}
+
+	return ops
+}
+
+//deleteChildren deletes the children of the node whose keys match
+func (t *tree) deleteChildren(node *Node, match func(key string) bool) []EventData {
+	ops := make([]EventData, 0)
+	for _, key := range node.ChildKeys() {
+		if match(key) {
+			deletedOps, _ := t.remove(appendPath(node.Path, key))
+			ops = append(ops, deletedOps...)
 		}
 	}
 
 	return ops
 }
 
-func (t tree) do(data map[string]interface{}, relative []string) ([]EventData, error) {
+//checkArrayPath returns an error if writing path would leave a hole in an array or
+//give it an element that is not an index, an element can be appended at the index after the last one
+func (t *tree) checkArrayPath(path Path) error {
+	node := t.root
+	for _, key := range path.Relative() {
+		if node.IsArray() {
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || strconv.Itoa(i) != key {
+				return ErrInvalidIndex
+			}
+
+			if i > node.childCount() {
+				return ErrIndexOutOfRange
+			}
+		}
+
+		child, ok := node.child(key)
+		if !ok {
+			return nil
+		}
+
+		node = child
+	}
+
+	return nil
+}
+
+func (t *tree) do(data map[string]interface{}, relative Path) ([]EventData, error) {
 	normalizedData, err := t.normalize(data, relative)
 	if err != nil {
 		return nil, err
@@ -133,7 +434,7 @@ func (t tree) do(data map[string]interface{}, relative []string) ([]EventData, e
 	return t.performOnNodes(normalizedData), nil
 }
 
-func (t tree) Set(data map[string]interface{}) ([]EventData, error) {
+func (t *tree) Set(data map[string]interface{}) ([]EventData, error) {
 	ops, err := t.do(data, []string{})
 	if err != nil {
 		return nil, err
@@ -142,27 +443,39 @@ func (t tree) Set(data map[string]interface{}) ([]EventData, error) {
 	return ops, nil
 }
 
-func (t tree) setTreePathData(path []string, data interface{}) (EventData, error) {
+func (t *tree) setTreePathData(path Path, data interface{}) (EventData, error) {
 	node := t.findNode(path, true)
 	var op EventOperation
+	var oldValue interface{}
 	if node.GetPristine() {
 		op = EventOperationInsert
 	} else {
 		op = EventOperationUpdate
+		oldValue = node.GetValue()
 	}
 
-	node.SetValue(data)
-	node.SetPristine(false)
+	revision := t.nextRevision()
+	t.setValue(node, data)
+	node.array = false
+	node.pristine = false
+	t.setAncestorsVersion(node.Path, revision)
 
 	return EventData{
 		Key:       node.Key,
 		Operation: op,
 		Path:      path,
 		Value:     data,
+		OldValue:  oldValue,
+		Version:   revision,
+		Index:     t.index(node),
 	}, nil
 }
 
-func (t tree) SetPath(path []string, data interface{}) ([]EventData, error) {
+func (t *tree) SetPath(path Path, data interface{}) ([]EventData, error) {
+	if err := t.checkArrayPath(path); err != nil {
+		return nil, err
+	}
+
 	var ops []EventData
 
 	switch d := data.(type) {
@@ -170,25 +483,25 @@ func (t tree) SetPath(path []string, data interface{}) ([]EventData, error) {
 		node := t.findNode(path, true)
 		diff := []string{}
 
-		for item := range node.Children.IterBuffered() {
-			if d[item.Key] == nil {
-				diff = append(diff, item.Key)
+		for _, key := range node.ChildKeys() {
+			if d[key] == nil {
+				diff = append(diff, key)
 			}
 		}
 
-		o, err := t.do(d, path)
-		if err != nil {
-			return nil, err
-		}
+		//the marker of the map itself turns an array node into an object
+		o := t.performOnNodes(t.normalizeValue(nil, d, path))
 
 		for _, k := range diff {
-			deletedOps, deleted := t.Delete(append(path, k))
+			deletedOps, deleted := t.remove(appendPath(path, k))
 			if deleted {
 				o = append(o, deletedOps...)
 			}
 		}
 
 		ops = o
+	case []interface{}:
+		ops = t.performOnNodes(t.normalizeValue(nil, d, path))
 	default:
 		op, err := t.setTreePathData(path, data)
 		if err != nil {
@@ -201,116 +514,359 @@ func (t tree) SetPath(path []string, data interface{}) ([]EventData, error) {
 	return ops, nil
 }
 
-func (t tree) iterateDescendants(node *Node, f func(node *Node), includeSelf bool) {
+//iterateDescendants calls f with the node and its descendants in tree order, together with their parents
+func (t *tree) iterateDescendants(node *Node, f func(node, parent *Node), includeSelf bool) {
+	var iterate func(node, parent *Node)
+	iterate = func(node, parent *Node) {
+		f(node, parent)
+		node.children.each(func(child *Node) {
+			iterate(child, node)
+		})
+	}
+
 	if includeSelf {
-		f(node)
+		iterate(node, t.parent(node))
+		return
 	}
 
-	var wg sync.WaitGroup
-	for item := range node.Children.IterBuffered() {
-		wg.Add(1)
-		go func(n *Node) {
-			defer wg.Done()
-			t.iterateDescendants(n, f, true)
-		}(item.Val.(*Node))
+	node.children.each(func(child *Node) {
+		iterate(child, node)
+	})
+}
+
+func (t *tree) Delete(path Path) ([]EventData, bool) {
+	if IsPattern(path) {
+		return t.deleteMatches(path)
 	}
 
-	wg.Wait()
+	node := t.findNode(path, false)
+	if node == nil {
+		return nil, false
+	}
+
+	if parent := t.parent(node); parent != nil && parent.IsArray() {
+		return t.removeElement(node), true
+	}
+
+	return t.deleteNode(node, t.nextRevision()), true
 }
 
-func (t tree) Delete(path []string) ([]EventData, bool) {
+//remove deletes the node at path, unlike Delete it leaves the other elements of an array where they are
+func (t *tree) remove(path Path) ([]EventData, bool) {
 	node := t.findNode(path, false)
 	if node == nil {
 		return nil, false
 	}
 
+	return t.deleteNode(node, t.nextRevision()), true
+}
+
+//removeElement deletes an element of an array like RemoveAt does, the elements after it move down
+func (t *tree) removeElement(node *Node) []EventData {
+	parent := t.parent(node)
+	for i, element := range t.arrayElements(parent) {
+		if element.Key == node.Key {
+			ops, _ := t.Splice(parent.Path, i, 1, nil)
+			return ops
+		}
+	}
+
+	return nil
+}
+
+//deleteNode removes node with its descendants and returns the delete events
+func (t *tree) deleteNode(node *Node, revision uint64) []EventData {
 	eventData := make([]EventData, 0) //TODO: optimize size
-	var lock sync.Mutex
 
-	t.iterateDescendants(node, func(node *Node) {
-		lock.Lock()
+	t.iterateDescendants(node, func(node, parent *Node) {
 		eventData = append(eventData, EventData{
 			Key:       node.Key,
 			Operation: EventOperationDelete,
 			Path:      node.Path,
 			Value:     node.value,
+			OldValue:  node.value,
+			Version:   revision,
+			Index:     elementIndex(parent, node),
 		})
+	}, true)
 
-		lock.Unlock()
-
-		parent := node.GetParent()
-		if parent != nil { //probably root
-			parent.Children.Remove(node.Key)
-		}
+	if node.Key == TreeRoot {
+		//the root itself stays, only its data is removed
+		root := t.mutable(Path{}, false)
+		root.children = nil
+		root.value = nil
+		root.size = nodeSize(TreeRoot)
+		return eventData
+	}
 
-		node.SetValue(nil)
-		node.SetParent(nil)
-	}, true)
+	parentPath := node.Path[:len(node.Path)-1]
+	t.setAncestorsVersion(parentPath, revision)
+	t.removeChild(t.mutable(parentPath, false), node.Key)
 
-	return eventData, true
+	return eventData
 }
 
-func (t tree) getJSON(node *Node, level int) interface{} {
-	res := make(map[string]interface{})
+func (t *tree) getJSON(node *Node) interface{} {
+	value, _ := t.getJSONContext(context.Background(), node)
+	return value
+}
 
+//getJSONContext is getJSON giving up with the error of ctx when it is done before the value is built
+func (t *tree) getJSONContext(ctx context.Context, node *Node) (interface{}, error) {
 	if node == nil {
-		return nil
+		return nil, nil
 	}
 
-	if node.Children.Count() == 0 {
+	if node.childCount() == 0 && !node.IsArray() {
 		val := node.GetValue()
 		if val == nil {
-			return res
+			return make(map[string]interface{}), nil
 		}
 
-		return val
+		return val, nil
 	}
 
-	var mutex sync.Mutex
-	setJSONValue := func(json map[string]interface{}, path []string, value interface{}) {
-		mutex.Lock()
-		defer mutex.Unlock()
+	return t.jsonValueContext(ctx, node)
+}
 
-		currentJSON := json
-		for i, p := range path {
-			if i == len(path)-1 {
-				currentJSON[p] = value
-			} else {
-				if currentJSON[p] == nil {
-					currentJSON[p] = make(map[string]interface{})
-				}
+func (t *tree) jsonValue(node *Node) interface{} {
+	value, _ := t.jsonValueContext(context.Background(), node)
+	return value
+}
+
+//jsonValueContext is jsonValue giving up with the error of ctx when it is done
+func (t *tree) jsonValueContext(ctx context.Context, node *Node) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-				currentJSON = currentJSON[p].(map[string]interface{})
+	if node.IsArray() {
+		elements := t.arrayElements(node)
+		res := make([]interface{}, len(elements))
+		for i, element := range elements {
+			value, err := t.jsonValueContext(ctx, element)
+			if err != nil {
+				return nil, err
 			}
+
+			res[i] = value
 		}
 
+		return res, nil
+	}
+
+	if node.childCount() == 0 {
+		return node.GetValue(), nil
 	}
 
-	t.iterateDescendants(node, func(childNode *Node) {
-		//we need to iterate only the leafs
-		if childNode.Children.Count() == 0 {
-			setJSONValue(res, childNode.Path[level:], childNode.GetValue())
+	var err error
+	res := make(map[string]interface{}, node.childCount())
+	node.children.each(func(child *Node) {
+		if err == nil {
+			res[child.Key], err = t.jsonValueContext(ctx, child)
 		}
-	}, false)
+	})
 
-	return res
+	return res, err
 }
 
-func (t tree) Get(path []string) (EventData, error) {
+//arrayElements returns the elements of an array node ordered by their index, an index
+//that is missing is skipped so that the elements after it are never lost
+func (t *tree) arrayElements(node *Node) []*Node {
+	elements := make([]*Node, 0, node.childCount())
+	node.children.each(func(child *Node) {
+		if i, err := strconv.Atoi(child.Key); err == nil && i >= 0 {
+			elements = append(elements, child)
+		}
+	})
+
+	return elements
+}
+
+//checkSplice validates a Splice before anything is changed, a missing node is an empty array
+func (t *tree) checkSplice(path Path, start, deleteCount int) error {
+	if err := t.checkArrayPath(path); err != nil {
+		return err
+	}
+
 	node := t.findNode(path, false)
+	if node == t.root {
+		return ErrNotArray
+	}
+
+	length := 0
+	if node != nil {
+		if !node.IsArray() && (node.childCount() > 0 || node.GetValue() != nil) {
+			return ErrNotArray
+		}
+
+		length = len(t.arrayElements(node))
+	}
+
+	if start < 0 || start > length || deleteCount < 0 {
+		return ErrIndexOutOfRange
+	}
+
+	return nil
+}
+
+//Splice removes deleteCount elements of the array at path starting from start and inserts items in their place.
+//The elements after them are moved to their new indexes, every index whose element changed gets an event.
+func (t *tree) Splice(path Path, start, deleteCount int, items []interface{}) ([]EventData, error) {
+	if err := t.checkSplice(path, start, deleteCount); err != nil {
+		return nil, err
+	}
+
+	node := t.findNode(path, true)
+	elements := t.arrayElements(node)
+	length := len(elements)
+	if start+deleteCount > length {
+		deleteCount = length - start
+	}
+
+	newLength := length - deleteCount + len(items)
+
+	//the elements before start are moved to their positions in case an index is missing
+	for i, element := range elements[:start] {
+		if element.Key != strconv.Itoa(i) {
+			t.removeChild(node, element.Key)
+			t.addChild(node, t.rekey(element, strconv.Itoa(i), node.Path))
+		}
+	}
+
+	//the indexes whose elements change are notified with their previous values,
+	//the ones that will not exist after the splice with their last value
+	oldValues := make(map[int]interface{})
+	for i := start; i < length; i++ {
+		oldValues[i] = t.jsonValue(elements[i])
+	}
 
-	var eventPath []string
+	for _, element := range elements[start:] {
+		t.removeChild(node, element.Key)
+	}
+
+	node.array = true
+	t.setValue(node, nil)
+
+	for i, item := range items {
+		if _, err := t.SetPath(appendPath(node.Path, strconv.Itoa(start+i)), item); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, moved := range elements[start+deleteCount:] {
+		if key := strconv.Itoa(start + len(items) + i); key != moved.Key {
+			moved = t.rekey(moved, key, node.Path)
+		}
+
+		t.addChild(node, moved)
+	}
+
+	revision := t.nextRevision()
+	t.setAncestorsVersion(node.Path, revision)
+
+	ops := make([]EventData, 0, len(node.Path)+newLength-start)
+	for i := range node.Path {
+		ancestor := t.mutable(node.Path[:i+1], false)
+
+		op := EventOperationUpdate
+		if ancestor.GetPristine() {
+			op = EventOperationInsert
+		}
+
+		info := EventData{
+			Key:       ancestor.Key,
+			Operation: op,
+			Path:      ancestor.Path,
+			Value:     ancestor.GetValue(),
+			Version:   revision,
+			Index:     t.index(ancestor),
+		}
+
+		if op == EventOperationUpdate {
+			info.OldValue = info.Value
+		}
+
+		ops = append(ops, info)
+
+		ancestor.pristine = false
+	}
+
+	for i := start; i < length || i < newLength; i++ {
+		index := i
+		if i >= newLength {
+			ops = append(ops, EventData{
+				Key:       strconv.Itoa(i),
+				Operation: EventOperationDelete,
+				Path:      appendPath(node.Path, strconv.Itoa(i)),
+				Value:     oldValues[i],
+				OldValue:  oldValues[i],
+				Version:   revision,
+				Index:     &index,
+			})
+
+			continue
+		}
+
+		element := t.mutable(appendPath(node.Path, strconv.Itoa(i)), false)
+		element.version = revision
+		element.pristine = false
+
+		op := EventOperationUpdate
+		if i >= length {
+			op = EventOperationInsert
+		}
+
+		ops = append(ops, EventData{
+			Key:       element.Key,
+			Operation: op,
+			Path:      element.Path,
+			Value:     t.jsonValue(element),
+			OldValue:  oldValues[i],
+			Version:   revision,
+			Index:     &index,
+		})
+	}
+
+	return ops, nil
+}
+
+func (t *tree) Get(path Path) (EventData, error) {
+	return t.get(context.Background(), path)
+}
+
+//get is Get giving up with the error of ctx when it is done, path patterns are given up between their matches
+func (t *tree) get(ctx context.Context, path Path) (EventData, error) {
+	if IsPattern(path) {
+		return t.getMatches(ctx, path)
+	}
+
+	return t.getNode(ctx, t.findNode(path, false), path) //TODO: not returning not found, i think it's fine
+}
+
+//getNode creates the event of a get of node, path is used when the node does not exist
+func (t *tree) getNode(ctx context.Context, node *Node, path Path) (EventData, error) {
+	var eventPath Path
 	if node != nil {
 		eventPath = node.Path
 	} else {
 		eventPath = path
 	}
 
-	eventValue := t.getJSON(node, len(path))
+	eventValue, err := t.getJSONContext(ctx, node)
+	if err != nil {
+		return EventData{}, err
+	}
 
 	var eventKey string
+	var eventVersion uint64
+	var eventIndex *int
+	var eventExpiresAt *time.Time
 	if node != nil {
 		eventKey = node.Key
+		eventVersion = node.GetVersion()
+		eventIndex = t.index(node)
+		eventExpiresAt = node.expiresAt()
 	} else {
 		eventKey = path[len(path)-1]
 	}
@@ -320,5 +876,8 @@ func (t tree) Get(path []string) (EventData, error) {
 		Operation: EventOperationGet,
 		Path:      eventPath,
 		Value:     eventValue,
-	}, nil //TODO: not returning not found, i think it's fine
+		Version:   eventVersion,
+		Index:     eventIndex,
+		ExpiresAt: eventExpiresAt,
+	}, nil
 }
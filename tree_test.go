@@ -3,25 +3,17 @@ package liquiddb
 import (
 	"reflect"
 	"testing"
-
-	"github.com/orcaman/concurrent-map"
 )
 
 func TestNewNode(t *testing.T) {
-	n := newNode(TreeRoot, &Node{
-		Key:      "ParentTest",
-		Children: cmap.New(),
-		parent:   nil,
-		pristine: false,
-		value:    nil,
-	})
+	n := newNode("child", Path{TreeRoot, "ParentTest"}, 1)
 
-	if n.Key != TreeRoot {
+	if n.Key != "child" {
 		t.Fatalf("Invalid node key %s", n.Key)
 	}
 
-	if n.parent == nil || n.parent.Key != "ParentTest" {
-		t.Fatalf("Invalid parent %+v", n.parent)
+	if !reflect.DeepEqual(n.Path, Path{"ParentTest", "child"}) {
+		t.Fatalf("Invalid path %v", n.Path)
 	}
 }
 
@@ -88,22 +80,62 @@ func TestTree_Delete(t *testing.T) {
 	}
 }
 
+func TestTree_OldValue(t *testing.T) {
+	tree := New()
+	tree.SetPath(p, 1)
+
+	ops, _ := tree.SetPath(p, 2)
+	if ops[0].Operation != EventOperationUpdate || ops[0].OldValue != 1 {
+		t.Fatalf("Invalid update op %+v", ops[0])
+	}
+
+	ops, _ = tree.Set(map[string]interface{}{"foo": map[string]interface{}{"bar": 3}})
+	if last := ops[len(ops)-1]; last.OldValue != 2 || last.Value != 3 {
+		t.Fatalf("Invalid set op %+v", last)
+	}
+
+	ops, _ = tree.Delete(p)
+	if ops[0].Operation != EventOperationDelete || ops[0].OldValue != 3 {
+		t.Fatalf("Invalid delete op %+v", ops[0])
+	}
+
+	ops, _ = tree.SetPath(p, 4)
+	if ops[0].Operation != EventOperationInsert || ops[0].OldValue != nil {
+		t.Fatalf("Invalid insert op %+v", ops[0])
+	}
+}
+
+func TestTree_SpliceOldValue(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	list := Path{"list"}
+	db.SetPath(list, []interface{}{1, 2, 3})
+	ops, _ := db.RemoveAt(list, 0)
+
+	oldValues := make(map[string]interface{})
+	for _, op := range ops {
+		oldValues[string(op.Operation)+" "+op.Key] = op.OldValue
+	}
+
+	expected := map[string]interface{}{"update list": nil, "update 0": 1, "update 1": 2, "delete 2": 3}
+	if !reflect.DeepEqual(oldValues, expected) {
+		t.Fatalf("Invalid old values %+v", oldValues)
+	}
+}
+
 func TestTree_DeleteAll(t *testing.T) {
 	tree := newTree()
 
 	tree.Set(data)
 
-	n, _ := tree.root.Children.Get("foo")
-	node := n.(*Node)
-
 	tree.Delete([]string{})
 
-	children := len(tree.root.Children)
-	if children > 0 {
+	if tree.root.childCount() > 0 {
 		t.Fatal("Invalid amount of children")
 	}
 
-	if node.parent != nil || node.GetValue() != nil {
+	if tree.findNode(Path{"foo"}, false) != nil || tree.root.GetSize() != nodeSize(TreeRoot) {
 		t.Fatal("Node is not deleted properly")
 	}
 }
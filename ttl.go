@@ -0,0 +1,189 @@
+package liquiddb
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+var (
+	//ErrInvalidTTL is returned when a TTL is not positive or is set on the root
+	ErrInvalidTTL = errors.New("Invalid TTL")
+)
+
+//setExpiry sets when the node at path expires, the zero time removes its TTL
+func (t *tree) setExpiry(path Path, expiry time.Time) error {
+	if len(path.Relative()) == 0 {
+		return ErrInvalidTTL
+	}
+
+	node := t.mutable(path, false)
+	if node == nil {
+		return ErrNotFound
+	}
+
+	node.expiry = expiry
+	if expiry.IsZero() {
+		delete(t.expiring, node.Path.String())
+	} else {
+		t.expiring[node.Path.String()] = node.Path
+	}
+
+	return nil
+}
+
+//expired returns the paths of the nodes that expire at or before now, ancestors
+//before their descendants. The paths of nodes that were removed or lost their TTL are forgotten.
+func (t *tree) expired(now time.Time) []Path {
+	paths := make([]Path, 0)
+	for key, path := range t.expiring {
+		node := t.findNode(path, false)
+		if node == nil || node.GetExpiry().IsZero() {
+			delete(t.expiring, key)
+			continue
+		}
+
+		if !node.GetExpiry().After(now) {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Slice(paths, func(i, j int) bool {
+		return len(paths[i]) < len(paths[j])
+	})
+
+	return paths
+}
+
+//isExpired returns whether the node at path exists and expires at or before now
+func (db LiquidDb) isExpired(path Path, now time.Time) bool {
+	db.treeMutex.RLock()
+	defer db.treeMutex.RUnlock()
+
+	node := db.tree.findNode(path, false)
+	if node == nil {
+		return false
+	}
+
+	expiry := node.GetExpiry()
+	return !expiry.IsZero() && !expiry.After(now)
+}
+
+//reapExpired deletes the nodes that expire at or before now and returns how many were deleted,
+//the deletes are regular writes whose events have EventReasonExpired
+func (db LiquidDb) reapExpired(now time.Time) int {
+	db.treeMutex.Lock()
+	paths := db.tree.expired(now)
+	db.treeMutex.Unlock()
+
+	reaped := 0
+	for _, path := range paths {
+		_, err := db.mutate(func() (logRecord, error) {
+			//the TTL could have been changed or the node deleted since it was found
+			if !db.isExpired(path, now) {
+				return logRecord{}, ErrNotFound
+			}
+
+			return logRecord{
+				Operation: logOperationDelete,
+				Path:      path,
+				Reason:    EventReasonExpired,
+			}, nil
+		})
+		if err == nil {
+			reaped++
+		}
+	}
+
+	return reaped
+}
+
+type reaper struct {
+	once sync.Once
+	stop chan struct{}
+	done chan struct{}
+}
+
+//startReaper deletes the expired nodes in the background every interval
+func (db *LiquidDb) startReaper(interval time.Duration) {
+	r := &reaper{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	db.reaper = r
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				db.reapExpired(now)
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+//close stops the reaper and waits for the reap in progress
+func (r *reaper) close() {
+	if r == nil {
+		return
+	}
+
+	r.once.Do(func() {
+		close(r.stop)
+		<-r.done
+	})
+}
+
+//SetPathWithTTL sets value by a path just like SetPath, the node at path
+//and all of its descendants are deleted once ttl passes
+func (db LiquidDb) SetPathWithTTL(path Path, data interface{}, ttl time.Duration) ([]EventData, error) {
+	if ttl <= 0 || len(trimRoot(path)) == 0 {
+		return nil, ErrInvalidTTL
+	}
+
+	return db.commit(logRecord{
+		Operation: logOperationSetPath,
+		Path:      path,
+		Value:     data,
+		Expiry:    time.Now().Add(ttl),
+	})
+}
+
+//SetTTL makes the existing node at path expire once ttl passes, replacing its previous TTL.
+//The TTL stays with the node when its value is written again.
+func (db LiquidDb) SetTTL(path Path, ttl time.Duration) error {
+	if ttl <= 0 || len(trimRoot(path)) == 0 {
+		return ErrInvalidTTL
+	}
+
+	_, err := db.commit(logRecord{
+		Operation: logOperationExpire,
+		Path:      path,
+		Expiry:    time.Now().Add(ttl),
+	})
+
+	return err
+}
+
+//ClearTTL removes the TTL of the node at path so it no longer expires
+func (db LiquidDb) ClearTTL(path Path) error {
+	if len(trimRoot(path)) == 0 {
+		return ErrInvalidTTL
+	}
+
+	_, err := db.commit(logRecord{
+		Operation: logOperationExpire,
+		Path:      path,
+	})
+
+	return err
+}
@@ -0,0 +1,180 @@
+package liquiddb
+
+import (
+	"testing"
+	"time"
+)
+
+var session = []string{"sessions", "s1"}
+
+func TestTTL_Reap(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	if _, err := db.SetPathWithTTL(session, map[string]interface{}{"user": "u1"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	v, _ := db.Get(session)
+	if v.ExpiresAt == nil || v.ExpiresAt.Before(time.Now()) {
+		t.Fatalf("Invalid expiry metadata %+v", v.ExpiresAt)
+	}
+
+	ch := make(chan EventData, 10)
+	db.Notify(ch, EventOperationDelete)
+
+	if reaped := db.reapExpired(time.Now()); reaped != 0 {
+		t.Fatalf("Reaped %d nodes before they expired", reaped)
+	}
+
+	if reaped := db.reapExpired(time.Now().Add(2 * time.Minute)); reaped != 1 {
+		t.Fatalf("Invalid reaped count %d", reaped)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case ev := <-ch:
+			if ev.Reason != EventReasonExpired {
+				t.Fatalf("Delete event without expiry reason %+v", ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Missing expiry delete event")
+		}
+	}
+
+	if v, _ := db.Get(session); v.Value != nil {
+		t.Fatalf("Expired node not deleted %+v", v.Value)
+	}
+}
+
+func TestTTL_SetAndClear(t *testing.T) {
+	db := New()
+	defer db.Close()
+
+	db.SetPath(session, "online")
+	if err := db.SetTTL(session, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetPath(session, "away")
+	if v, _ := db.Get(session); v.ExpiresAt == nil {
+		t.Fatal("Write removed the TTL")
+	}
+
+	if err := db.ClearTTL(session); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := db.Get(session); v.ExpiresAt != nil {
+		t.Fatalf("TTL not cleared %+v", v.ExpiresAt)
+	}
+
+	if reaped := db.reapExpired(time.Now().Add(2 * time.Minute)); reaped != 0 {
+		t.Fatalf("Reaped node without TTL")
+	}
+
+	if err := db.SetTTL([]string{"missing"}, time.Minute); err != ErrNotFound {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if err := db.SetTTL(session, 0); err != ErrInvalidTTL {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if err := db.SetTTL([]string{TreeRoot}, time.Minute); err != ErrInvalidTTL {
+		t.Fatalf("Invalid error %v", err)
+	}
+}
+
+func TestTTL_Background(t *testing.T) {
+	db, err := NewWithConfig(NewConfigBuilder().ReapInterval(10 * time.Millisecond).Finalize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.SetPathWithTTL(session, "online", 20*time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if v, _ := db.Get(session); v.Value == nil {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("Expired node was not reaped")
+}
+
+func TestTTL_Persistence(t *testing.T) {
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+
+	db := openPersistent(t, path)
+	db.SetPathWithTTL(session, "online", time.Minute)
+	db.SetPathWithTTL([]string{"sessions", "s2"}, "online", time.Minute)
+	db.reapExpired(time.Now().Add(2 * time.Minute))
+	db.SetPathWithTTL(session, "online", time.Hour)
+	db.Close()
+
+	db = openPersistent(t, path)
+	v, _ := db.Get(session)
+	if v.ExpiresAt == nil || v.ExpiresAt.Before(time.Now().Add(time.Minute)) {
+		t.Fatalf("Invalid replayed expiry %+v", v.ExpiresAt)
+	}
+
+	if v, _ := db.Get([]string{"sessions", "s2"}); v.Value != nil {
+		t.Fatalf("Reaped node replayed %+v", v.Value)
+	}
+
+	snapshotPath, cleanupSnapshot := tempSnapshotPath(t)
+	defer cleanupSnapshot()
+
+	if err := db.Snapshot(snapshotPath); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	restored, err := NewFromSnapshot(snapshotPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+
+	if v, _ := restored.Get(session); v.ExpiresAt == nil {
+		t.Fatal("Expiry not restored from snapshot")
+	}
+
+	if reaped := restored.reapExpired(time.Now().Add(2 * time.Hour)); reaped != 1 {
+		t.Fatalf("Restored node not reaped, reaped %d", reaped)
+	}
+}
+
+func TestTTL_ZeroConfig(t *testing.T) {
+	//intervals which are not positive get the default instead of crashing the background goroutines
+	db, err := NewWithConfig(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetPathWithTTL(session, 1, time.Hour)
+	time.Sleep(10 * time.Millisecond)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+
+	db, err = NewWithConfig(Config{LogPath: path, SyncPolicy: SyncInterval})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetPath(session, 1)
+	time.Sleep(10 * time.Millisecond)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
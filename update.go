@@ -0,0 +1,185 @@
+package liquiddb
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/go-errors/errors"
+)
+
+var (
+	//ErrInvalidUpdate is returned when the root is updated with something that is not an object
+	ErrInvalidUpdate = errors.New("Invalid update, the root can only be updated with an object")
+)
+
+type leaf struct {
+	key   string
+	path  Path
+	value interface{}
+	index *int
+}
+
+//leaves returns the nodes without children of the subtree at path in tree order, keyed by their paths
+func (t *tree) leaves(path Path) ([]leaf, map[string]leaf) {
+	ordered := make([]leaf, 0)
+	byPath := make(map[string]leaf)
+
+	node := t.findNode(path, false)
+	if node == nil {
+		return ordered, byPath
+	}
+
+	t.iterateDescendants(node, func(n, parent *Node) {
+		if n.childCount() > 0 {
+			return
+		}
+
+		l := leaf{key: n.Key, path: n.Path, value: t.jsonValue(n), index: elementIndex(parent, n)}
+		ordered = append(ordered, l)
+		byPath[l.path.String()] = l
+	}, true)
+
+	return ordered, byPath
+}
+
+//diffLeaves calls write and returns an event for every leaf of the subtree at path that it removed, inserted or changed
+func (t *tree) diffLeaves(path Path, write func()) []EventData {
+	before, beforeByPath := t.leaves(path)
+	write()
+	after, afterByPath := t.leaves(path)
+
+	ops := make([]EventData, 0)
+	for _, l := range before {
+		if _, ok := afterByPath[l.path.String()]; !ok {
+			ops = append(ops, EventData{
+				Key:       l.key,
+				Operation: EventOperationDelete,
+				Path:      l.path,
+				Value:     l.value,
+				OldValue:  l.value,
+				Index:     l.index,
+			})
+		}
+	}
+
+	for _, l := range after {
+		op := EventOperationInsert
+		var oldValue interface{}
+		if old, ok := beforeByPath[l.path.String()]; ok {
+			if reflect.DeepEqual(old.value, l.value) {
+				continue
+			}
+
+			op = EventOperationUpdate
+			oldValue = old.value
+		}
+
+		ops = append(ops, EventData{
+			Key:       l.key,
+			Operation: op,
+			Path:      l.path,
+			Value:     l.value,
+			OldValue:  oldValue,
+			Index:     l.index,
+		})
+	}
+
+	return ops
+}
+
+//isObject returns whether a partial object can be merged into the node
+func isObject(node *Node) bool {
+	return !node.IsArray() && (node.childCount() > 0 || node.GetValue() == nil)
+}
+
+//update merges value into the node at path and returns the leaf events without versions
+func (t *tree) update(path Path, value interface{}) []EventData {
+	partial, isMap := value.(map[string]interface{})
+	node := t.findNode(path, false)
+
+	if isMap && (node == nil || isObject(node)) {
+		keys := make([]string, 0, len(partial))
+		for key := range partial {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return compareKeys(keys[i], keys[j]) < 0
+		})
+
+		ops := make([]EventData, 0)
+		for _, key := range keys {
+			ops = append(ops, t.update(appendPath(path, key), partial[key])...)
+		}
+
+		return ops
+	}
+
+	return t.diffLeaves(path, func() {
+		//whatever is at path is replaced, so it is deleted first, a deleted array element is removed like RemoveAt does
+		switch {
+		case node == nil:
+		case value == nil && t.index(node) != nil:
+			t.removeElement(node)
+		default:
+			t.remove(path)
+		}
+
+		switch {
+		case value == nil:
+		case isMap:
+			t.update(path, partial)
+		default:
+			t.SetPath(path, value)
+		}
+	})
+}
+
+//Update merges partial into the node at path. Objects are merged recursively, a nil value
+//deletes its key and any other value replaces the node at its key, arrays included.
+//Every leaf that was changed, inserted or removed gets exactly one event.
+func (t *tree) Update(path Path, partial interface{}) ([]EventData, error) {
+	if err := t.checkArrayPath(path); err != nil {
+		return nil, err
+	}
+
+	ops := t.update(path, partial)
+	if len(ops) == 0 {
+		return ops, nil
+	}
+
+	revision := t.nextRevision()
+	for i := range ops {
+		ops[i].Version = revision
+
+		//the nodes of deletes are gone, their closest remaining ancestor gets the version
+		path := Path{}
+		for p := ops[i].Path; len(p) > 0; p = p[:len(p)-1] {
+			if t.findNode(p, false) != nil {
+				path = p
+				break
+			}
+		}
+
+		t.setAncestorsVersion(path, revision)
+	}
+
+	return ops, nil
+}
+
+//Update merges partial into the value at path, see tree.Update for the merge semantics
+func (db LiquidDb) Update(path Path, partial interface{}) ([]EventData, error) {
+	return db.commit(logRecord{
+		Operation: logOperationUpdate,
+		Path:      path,
+		Value:     partial,
+	})
+}
+
+//Update stages merging partial into the value at path
+func (tx *Tx) Update(path Path, partial interface{}) error {
+	return tx.stage(logRecord{
+		Operation: logOperationUpdate,
+		Path:      path,
+		Value:     partial,
+	})
+}
@@ -0,0 +1,106 @@
+package liquiddb
+
+import (
+	"reflect"
+	"testing"
+)
+
+var profile = Path{"users", "u1"}
+
+func TestUpdate_Merge(t *testing.T) {
+	db := New()
+	db.SetPath(profile, map[string]interface{}{
+		"name":    "a",
+		"age":     1,
+		"address": map[string]interface{}{"city": "x", "zip": "1"},
+		"tags":    []interface{}{"t1"},
+	})
+
+	ops, err := db.Update(profile, map[string]interface{}{
+		"name":    "a",
+		"age":     2,
+		"address": map[string]interface{}{"zip": nil, "street": "y"},
+		"tags":    []interface{}{"t1", "t2"},
+		"missing": nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := map[string]EventOperation{}
+	for _, op := range ops {
+		if _, ok := events[op.Path.String()]; ok {
+			t.Fatalf("More than one event for %s", op.Path)
+		}
+
+		events[op.Path.String()] = op.Operation
+		if op.Version != ops[0].Version {
+			t.Fatalf("Update is not a single write %+v", ops)
+		}
+	}
+
+	expectedEvents := map[string]EventOperation{
+		"users.u1.age":            EventOperationUpdate,
+		"users.u1.address.zip":    EventOperationDelete,
+		"users.u1.address.street": EventOperationInsert,
+		"users.u1.tags.1":         EventOperationInsert,
+	}
+	if !reflect.DeepEqual(events, expectedEvents) {
+		t.Fatalf("Invalid events %+v", events)
+	}
+
+	v, _ := db.Get(profile)
+	expected := map[string]interface{}{
+		"name":    "a",
+		"age":     2,
+		"address": map[string]interface{}{"city": "x", "street": "y"},
+		"tags":    []interface{}{"t1", "t2"},
+	}
+	if !reflect.DeepEqual(v.Value, expected) {
+		t.Fatalf("Invalid merged value %+v", v.Value)
+	}
+}
+
+func TestUpdate_Replace(t *testing.T) {
+	db := New()
+	db.SetPath(profile, map[string]interface{}{"address": "x", "tags": map[string]interface{}{"a": 1}})
+
+	ops, _ := db.Update(profile, map[string]interface{}{
+		"address": map[string]interface{}{"city": "y"},
+		"tags":    "none",
+	})
+	if len(ops) != 4 {
+		t.Fatalf("Invalid events %+v", ops)
+	}
+
+	v, _ := db.Get(profile)
+	expected := map[string]interface{}{
+		"address": map[string]interface{}{"city": "y"},
+		"tags":    "none",
+	}
+	if !reflect.DeepEqual(v.Value, expected) {
+		t.Fatalf("Invalid replaced value %+v", v.Value)
+	}
+
+	if _, err := db.Update(Path{}, 1); err != ErrInvalidUpdate {
+		t.Fatalf("Invalid error %v", err)
+	}
+}
+
+func TestUpdate_Replay(t *testing.T) {
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+
+	db := openPersistent(t, path)
+	db.Set(data)
+	db.Update(Path{"foo"}, map[string]interface{}{"bar": nil, "baz": 1})
+	db.Close()
+
+	db = openPersistent(t, path)
+	defer db.Close()
+
+	v, _ := db.Get(Path{"foo"})
+	if !reflect.DeepEqual(v.Value, map[string]interface{}{"baz": 1}) {
+		t.Fatalf("Invalid replayed update %+v", v.Value)
+	}
+}
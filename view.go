@@ -0,0 +1,58 @@
+package liquiddb
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+//publishedRoot is the data as it was after a write
+type publishedRoot struct {
+	root     *Node
+	revision uint64
+}
+
+//publish swaps the data of a write in for the readers, the caller must hold the lock of the tree.
+//The nodes of the write become immutable, the next write copies the ones it changes.
+func (t *tree) publish() {
+	t.published.Store(publishedRoot{root: t.root, revision: t.currentRevision()})
+	t.owner = nextOwner()
+}
+
+//rollback drops the changes made since the last publish, the caller must hold the lock of the tree
+func (t *tree) rollback() {
+	published := t.published.Load().(publishedRoot)
+	t.root = published.root
+	atomic.StoreUint64(t.revision, published.revision)
+	t.owner = nextOwner()
+}
+
+//ReadView is a consistent snapshot of the data as it was after a write. Reading it never waits
+//for the writers and it never changes, the writes made after it was taken are not visible in it.
+type ReadView struct {
+	root publishedRoot
+}
+
+//ReadView takes a snapshot of the data without waiting for the writers
+func (db LiquidDb) ReadView() *ReadView {
+	return &ReadView{root: db.tree.published.Load().(publishedRoot)}
+}
+
+//Revision returns the revision of the last write visible in the view
+func (v *ReadView) Revision() uint64 {
+	return v.root.revision
+}
+
+//tree returns a tree reading the data of the view, it must never be written
+func (v *ReadView) tree() *tree {
+	return &tree{root: v.root.root}
+}
+
+//Get gets a value by a path like LiquidDb.Get does
+func (v *ReadView) Get(path Path) (EventData, error) {
+	return v.get(context.Background(), path)
+}
+
+//get is Get giving up with the error of ctx when it is done before the value is built
+func (v *ReadView) get(ctx context.Context, path Path) (EventData, error) {
+	return v.tree().get(ctx, path)
+}
@@ -0,0 +1,398 @@
+package liquiddb
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/orcaman/concurrent-map"
+	"github.com/sasha-s/go-deadlock"
+)
+
+func TestView_ReadView(t *testing.T) {
+	db := New()
+	db.SetPath(p, 1)
+
+	view := db.ReadView()
+	db.SetPath(p, 2)
+
+	if v, _ := view.Get(p); v.Value != 1 || view.Revision() != 1 {
+		t.Fatalf("Read view changed %+v", v)
+	}
+
+	if v, _ := db.ReadView().Get(p); v.Value != 2 || db.ReadView().Revision() != 2 {
+		t.Fatalf("Invalid read view %+v", v)
+	}
+
+	if v, err := view.Get(Path{"foo", PatternAny}); err != nil || len(v.Matches) != 1 || v.Matches[0].Value != 1 {
+		t.Fatalf("Invalid pattern read %+v %v", v, err)
+	}
+}
+
+func TestView_Sharing(t *testing.T) {
+	db := New()
+	db.Set(map[string]interface{}{"a": map[string]interface{}{"b": 1}, "c": map[string]interface{}{"d": 1}})
+
+	before := db.ReadView()
+	db.SetPath(Path{"a", "b"}, 2)
+	after := db.ReadView()
+
+	child := func(v *ReadView, key string) *Node {
+		node, _ := v.root.root.child(key)
+		return node
+	}
+
+	if child(before, "c") != child(after, "c") {
+		t.Fatal("Unchanged subtree was copied")
+	}
+
+	if child(before, "a") == child(after, "a") {
+		t.Fatal("Changed subtree was shared")
+	}
+
+	if v, _ := before.Get(Path{"a", "b"}); v.Value != 1 {
+		t.Fatalf("Published node changed %+v", v)
+	}
+}
+
+func TestView_MatchesTree(t *testing.T) {
+	db := New()
+	db.Set(map[string]interface{}{"foo": map[string]interface{}{"bar": "a", "list": []interface{}{1, 2, 3}}})
+	db.Splice(Path{"foo", "list"}, 0, 1)
+	db.Push(Path{"foo", "list"}, map[string]interface{}{"x": 1})
+	db.Update(Path{"foo"}, map[string]interface{}{"bar": nil, "baz": "b"})
+	db.SetPathWithTTL(Path{"foo", "ttl"}, 1, time.Hour)
+	db.Delete(Path{"foo", "list", "0"})
+	db.SetPath(Path{"empty"}, map[string]interface{}{})
+
+	paths := []Path{{"foo"}, {"foo", "list"}, {"foo", "list", "2"}, {"foo", "ttl"}, {"foo", "bar"}, {"empty"}, {TreeRoot}, {"foo", "list", "*"}, {"**"}}
+	for _, path := range paths {
+		db.treeMutex.RLock()
+		expected, _ := db.tree.Get(path)
+		db.treeMutex.RUnlock()
+
+		actual, _ := db.ReadView().Get(path)
+		if !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("Read view differs at %s\n%+v\n%+v", path, expected, actual)
+		}
+	}
+}
+
+func TestView_Rollback(t *testing.T) {
+	tree := newTree()
+	tree.Set(map[string]interface{}{"a": map[string]interface{}{"b": 1}})
+	tree.publish()
+	published := tree.root
+
+	tree.SetPath(Path{"a", "b"}, 2)
+	tree.SetPath(Path{"c"}, 3)
+	tree.rollback()
+
+	if tree.root != published || tree.currentRevision() != 1 {
+		t.Fatalf("Changes were not dropped, revision %d", tree.currentRevision())
+	}
+
+	if v, _ := tree.Get(Path{"a", "b"}); v.Value != 1 {
+		t.Fatalf("Published node changed %+v", v)
+	}
+
+	//the nodes of the dropped changes are never reused
+	tree.SetPath(Path{"a", "b"}, 4)
+	if node, _ := published.child("a"); tree.root == published || node.GetSize() != nodeSize("a")+nodeSize("b")+8 {
+		t.Fatal("Published node changed in place")
+	}
+}
+
+func TestView_ConsistentReads(t *testing.T) {
+	db := New()
+	db.Set(map[string]interface{}{"accounts": map[string]interface{}{"a": 50, "b": 50}})
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			db.Transaction(func(tx *Tx) error {
+				tx.SetPath(Path{"accounts", "a"}, 50-i%10)
+				tx.SetPath(Path{"accounts", "b"}, 50+i%10)
+				return nil
+			})
+		}
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			wg.Wait()
+			return
+		default:
+		}
+
+		view := db.ReadView()
+		a, _ := view.Get(Path{"accounts", "a"})
+		b, _ := view.Get(Path{"accounts", "b"})
+		if a.Value.(int)+b.Value.(int) != 100 {
+			t.Fatalf("Inconsistent read %v %v", a.Value, b.Value)
+		}
+	}
+}
+
+//baselineNode is the node of the tree before it was made persistent,
+//every field had its own lock and the children were kept in a concurrent map
+type baselineNode struct {
+	Key      string
+	Path     []string
+	Children cmap.ConcurrentMap
+
+	valueMutex deadlock.Mutex
+	value      interface{}
+
+	parentMutex deadlock.Mutex
+	parent      *baselineNode
+
+	pristineMutex deadlock.Mutex
+	pristine      bool
+}
+
+func newBaselineNode(key string, parent *baselineNode) *baselineNode {
+	parentPath := []string{}
+	if parent != nil && parent.Key != TreeRoot {
+		parentPath = parent.Path
+	}
+
+	node := &baselineNode{
+		Key:      key,
+		parent:   parent,
+		Children: cmap.New(),
+		Path:     append(append([]string{}, parentPath...), key),
+		pristine: true,
+	}
+
+	if parent != nil {
+		parent.Children.Set(node.Key, node)
+		parent.setValue(nil)
+	}
+
+	return node
+}
+
+func (n *baselineNode) getValue() interface{} {
+	n.valueMutex.Lock()
+	defer n.valueMutex.Unlock()
+
+	return n.value
+}
+
+func (n *baselineNode) setValue(v interface{}) {
+	n.valueMutex.Lock()
+	defer n.valueMutex.Unlock()
+
+	n.value = v
+}
+
+func (n *baselineNode) setPristine(p bool) {
+	n.pristineMutex.Lock()
+	defer n.pristineMutex.Unlock()
+
+	n.pristine = p
+}
+
+//baselineTree reads and writes the nodes like the tree did before it was made persistent, without a tree lock
+type baselineTree struct {
+	root *baselineNode
+}
+
+func (t baselineTree) findNode(path []string, autoCreate bool) *baselineNode {
+	node := t.root
+	for _, key := range path {
+		if _, ok := node.Children.Get(key); !ok {
+			if !autoCreate {
+				return nil
+			}
+
+			newBaselineNode(key, node)
+		}
+
+		n, _ := node.Children.Get(key)
+		node = n.(*baselineNode)
+	}
+
+	return node
+}
+
+func (t baselineTree) setPath(path []string, value interface{}) {
+	node := t.findNode(path, true)
+	node.setValue(value)
+	node.setPristine(false)
+}
+
+func (t baselineTree) iterateDescendants(node *baselineNode, f func(node *baselineNode), includeSelf bool) {
+	if includeSelf {
+		f(node)
+	}
+
+	var wg sync.WaitGroup
+	for item := range node.Children.IterBuffered() {
+		wg.Add(1)
+		go func(n *baselineNode) {
+			defer wg.Done()
+			t.iterateDescendants(n, f, true)
+		}(item.Val.(*baselineNode))
+	}
+
+	wg.Wait()
+}
+
+func (t baselineTree) getJSON(node *baselineNode, level int) interface{} {
+	if node == nil {
+		return nil
+	}
+
+	res := make(map[string]interface{})
+	if node.Children.Count() == 0 {
+		if val := node.getValue(); val != nil {
+			return val
+		}
+
+		return res
+	}
+
+	var mutex sync.Mutex
+	t.iterateDescendants(node, func(child *baselineNode) {
+		if child.Children.Count() > 0 {
+			return
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		json := res
+		path := child.Path[level:]
+		for _, key := range path[:len(path)-1] {
+			if json[key] == nil {
+				json[key] = make(map[string]interface{})
+			}
+
+			json = json[key].(map[string]interface{})
+		}
+		json[path[len(path)-1]] = child.getValue()
+	}, false)
+
+	return res
+}
+
+func (t baselineTree) get(path []string) EventData {
+	node := t.findNode(path, false)
+	return EventData{
+		Key:       path[len(path)-1],
+		Operation: EventOperationGet,
+		Path:      path,
+		Value:     t.getJSON(node, len(path)),
+	}
+}
+
+func benchmarkBaseline() baselineTree {
+	t := baselineTree{root: newBaselineNode(TreeRoot, nil)}
+	for i := 0; i < 1000; i++ {
+		t.setPath([]string{"users", strconv.Itoa(i), "name"}, "user")
+		t.setPath([]string{"users", strconv.Itoa(i), "age"}, i)
+	}
+
+	return t
+}
+
+func benchmarkDb(b *testing.B) *LiquidDb {
+	db := New()
+	for i := 0; i < 1000; i++ {
+		db.SetPath(Path{"users", strconv.Itoa(i)}, map[string]interface{}{"name": "user", "age": i})
+	}
+
+	return db
+}
+
+//benchmarkReads reads with get while a writer keeps changing the data with set
+func benchmarkReads(b *testing.B, set func(path Path, value int), get func(path Path)) {
+	stop := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				set(Path{"users", strconv.Itoa(i % 1000), "age"}, i)
+			}
+		}
+	}()
+	defer close(stop)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			get(Path{"users", strconv.Itoa(i % 1000)})
+			i++
+		}
+	})
+}
+
+//BenchmarkGet_Baseline reads the tree as it was before it was made persistent, for comparison with BenchmarkGet
+func BenchmarkGet_Baseline(b *testing.B) {
+	t := benchmarkBaseline()
+	benchmarkReads(b, func(path Path, value int) {
+		t.setPath(path, value)
+	}, func(path Path) {
+		t.get(path)
+	})
+}
+
+func BenchmarkGet(b *testing.B) {
+	db := benchmarkDb(b)
+	benchmarkReads(b, func(path Path, value int) {
+		db.SetPath(path, value)
+	}, func(path Path) {
+		db.Get(path)
+	})
+}
+
+func BenchmarkGet_ReadView(b *testing.B) {
+	db := benchmarkDb(b)
+	benchmarkReads(b, func(path Path, value int) {
+		db.SetPath(path, value)
+	}, func(path Path) {
+		db.ReadView().Get(path)
+	})
+}
+
+//BenchmarkSetPath_Baseline writes the tree as it was before it was made persistent, for comparison with BenchmarkSetPath_Tree
+func BenchmarkSetPath_Baseline(b *testing.B) {
+	t := benchmarkBaseline()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t.setPath([]string{"users", strconv.Itoa(i % 1000), "age"}, i)
+	}
+}
+
+//BenchmarkSetPath_Tree writes and publishes the tree without the rest of a write, the log, the events and the locks
+func BenchmarkSetPath_Tree(b *testing.B) {
+	db := benchmarkDb(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db.tree.SetPath(Path{"users", strconv.Itoa(i % 1000), "age"}, i)
+		db.tree.publish()
+	}
+}
+
+func BenchmarkSetPath(b *testing.B) {
+	db := benchmarkDb(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db.SetPath(Path{"users", strconv.Itoa(i % 1000), "age"}, i)
+	}
+}
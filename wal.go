@@ -0,0 +1,411 @@
+package liquiddb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/sasha-s/go-deadlock"
+)
+
+const (
+	//every record starts with its payload length and the payload's crc
+	logRecordHeaderSize = 8
+	//anything bigger than this can only be a corrupted length
+	logRecordMaxSize = 1 << 30
+)
+
+var (
+	//ErrInvalidSyncPolicy is returned when the config holds an unknown SyncPolicy
+	ErrInvalidSyncPolicy = errors.New("Invalid sync policy")
+
+	logChecksumTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+func init() {
+	//values are stored as interface{}, so gob needs to know the json container types
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+type logOperation uint8
+
+const (
+	logOperationSet logOperation = iota + 1
+	logOperationSetPath
+	logOperationDelete
+	logOperationBatch
+	logOperationSplice
+	logOperationExpire
+	logOperationUpdate
+	logOperationIncrement
+	logOperationMin
+	logOperationMax
+	logOperationAppend
+)
+
+//logRecord is a single mutation as it is stored in the write-ahead log,
+//batches hold the records of a transaction so they are replayed all or nothing
+type logRecord struct {
+	Operation logOperation
+	Path      Path
+	Value     interface{}
+	Records   []logRecord
+
+	//Index and Count are the start and the delete count of a splice
+	Index int
+	Count int
+
+	//Expiry is the absolute expiry time of a TTL, so replaying keeps the original deadline
+	Expiry time.Time
+	//Reason is carried to the events of a delete
+	Reason EventReason
+}
+
+func (r logRecord) apply(t *tree) ([]EventData, error) {
+	switch r.Operation {
+	case logOperationSet:
+		data, _ := r.Value.(map[string]interface{})
+		return t.Set(data)
+	case logOperationSetPath:
+		ops, err := t.SetPath(r.Path, r.Value)
+		if err != nil || r.Expiry.IsZero() {
+			return ops, err
+		}
+
+		return ops, t.setExpiry(r.Path, r.Expiry)
+	case logOperationExpire:
+		return nil, t.setExpiry(r.Path, r.Expiry)
+	case logOperationDelete:
+		ops, ok := t.Delete(r.Path)
+		if !ok {
+			return nil, ErrNotFound
+		}
+
+		for i := range ops {
+			ops[i].Reason = r.Reason
+		}
+
+		return ops, nil
+	case logOperationUpdate:
+		return t.Update(r.Path, r.Value)
+	case logOperationIncrement, logOperationMin, logOperationMax, logOperationAppend:
+		return t.atomic(r.Operation, r.Path, r.Value)
+	case logOperationSplice:
+		items, _ := r.Value.([]interface{})
+		return t.Splice(r.Path, r.Index, r.Count, items)
+	case logOperationBatch:
+		ops := make([]EventData, 0)
+		for _, record := range r.Records {
+			o, err := record.apply(t)
+			if err != nil && err != ErrNotFound {
+				return nil, err
+			}
+
+			ops = append(ops, o...)
+		}
+
+		return ops, nil
+	default:
+		return nil, errors.Errorf("Invalid log operation %d", r.Operation)
+	}
+}
+
+//validate returns an error if the record writes keys that cannot be written
+func (r logRecord) validate() error {
+	switch r.Operation {
+	case logOperationSet:
+		return validateValue(Path{}, r.Value)
+	case logOperationSetPath, logOperationSplice, logOperationAppend:
+		if err := r.Path.Validate(); err != nil {
+			return err
+		}
+
+		return validateValue(r.Path, r.Value)
+	case logOperationUpdate:
+		if _, ok := r.Value.(map[string]interface{}); !ok && len(r.Path.Relative()) == 0 {
+			return ErrInvalidUpdate
+		}
+
+		if err := r.Path.Validate(); err != nil {
+			return err
+		}
+
+		return validateValue(r.Path, r.Value)
+	case logOperationExpire, logOperationIncrement, logOperationMin, logOperationMax:
+		return r.Path.Validate()
+	case logOperationBatch:
+		for _, record := range r.Records {
+			if err := record.validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+//check returns the error applying the record to t would fail with, so a record which cannot be applied
+//never reaches the log. The records of a batch are checked when the transaction stages them,
+//against the data the records before them leave.
+func (r logRecord) check(t *tree) error {
+	switch r.Operation {
+	case logOperationSetPath:
+		if !r.Expiry.IsZero() && len(r.Path.Relative()) == 0 {
+			return ErrInvalidTTL
+		}
+
+		return t.checkArrayPath(r.Path)
+	case logOperationUpdate:
+		return t.checkArrayPath(r.Path)
+	case logOperationDelete:
+		//a delete of nothing would be replayed as a write that failed
+		if !t.exists(r.Path) {
+			return ErrNotFound
+		}
+	case logOperationExpire:
+		node := t.findNode(r.Path, false)
+		if node == nil {
+			return ErrNotFound
+		}
+
+		if node == t.root {
+			return ErrInvalidTTL
+		}
+	case logOperationIncrement, logOperationMin, logOperationMax, logOperationAppend:
+		return t.checkAtomic(r.Operation, r.Path, r.Value)
+	case logOperationSplice:
+		return t.checkSplice(r.Path, r.Index, r.Count)
+	}
+
+	return nil
+}
+
+type writeAheadLog struct {
+	mu deadlock.Mutex
+
+	file   *os.File
+	offset int64
+	//last is the offset of the last appended record
+	last int64
+
+	policy    SyncPolicy
+	batchSize int
+	unsynced  int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func openWriteAheadLog(config Config) (*writeAheadLog, error) {
+	switch config.SyncPolicy {
+	case SyncEveryWrite, SyncBatch, SyncInterval:
+	default:
+		return nil, ErrInvalidSyncPolicy
+	}
+
+	file, err := os.OpenFile(config.LogPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &writeAheadLog{
+		file:      file,
+		policy:    config.SyncPolicy,
+		batchSize: config.SyncBatchSize,
+	}
+
+	if l.policy == SyncInterval {
+		l.stop = make(chan struct{})
+		l.done = make(chan struct{})
+		go l.syncLoop(config.SyncInterval)
+	}
+
+	return l, nil
+}
+
+//replay calls f for every record in the log, a torn or corrupted
+//record at the end of the log is truncated away together with everything after it
+func (l *writeAheadLog) replay(f func(logRecord) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(l.file)
+	header := make([]byte, logRecordHeaderSize)
+	var offset int64
+
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			if err == io.ErrUnexpectedEOF {
+				return l.truncate(offset)
+			}
+
+			return err
+		}
+
+		size := binary.BigEndian.Uint32(header[:4])
+		checksum := binary.BigEndian.Uint32(header[4:])
+		if size > logRecordMaxSize {
+			return l.truncate(offset)
+		}
+
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return l.truncate(offset)
+			}
+
+			return err
+		}
+
+		if crc32.Checksum(payload, logChecksumTable) != checksum {
+			return l.truncate(offset)
+		}
+
+		var record logRecord
+		if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&record); err != nil {
+			return l.truncate(offset)
+		}
+
+		if err := f(record); err != nil {
+			return err
+		}
+
+		offset += int64(logRecordHeaderSize + len(payload))
+	}
+
+	l.offset = offset
+	return nil
+}
+
+func (l *writeAheadLog) truncate(offset int64) error {
+	if err := l.file.Truncate(offset); err != nil {
+		return err
+	}
+
+	l.offset = offset
+	return l.file.Sync()
+}
+
+func (l *writeAheadLog) append(record logRecord) error {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(record); err != nil {
+		return err
+	}
+
+	buf := make([]byte, logRecordHeaderSize, logRecordHeaderSize+payload.Len())
+	binary.BigEndian.PutUint32(buf[:4], uint32(payload.Len()))
+	binary.BigEndian.PutUint32(buf[4:], crc32.Checksum(payload.Bytes(), logChecksumTable))
+	buf = append(buf, payload.Bytes()...)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Write(buf); err != nil {
+		//do not leave a torn record behind, the next append would end up after it
+		l.file.Truncate(l.offset)
+		return err
+	}
+
+	offset := l.offset
+	l.last = offset
+	l.offset += int64(len(buf))
+	l.unsynced++
+
+	var err error
+	switch l.policy {
+	case SyncEveryWrite:
+		err = l.sync()
+	case SyncBatch:
+		if l.unsynced >= l.batchSize {
+			err = l.sync()
+		}
+	}
+
+	if err != nil {
+		//the caller is told the write failed, so it must not be replayed after a restart
+		l.unsynced--
+		l.file.Truncate(offset)
+		l.offset = offset
+	}
+
+	return err
+}
+
+//discard removes the last appended record, it is called when the record turned out to fail
+//when it was applied so it must not be replayed after a restart
+func (l *writeAheadLog) discard() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.truncate(l.last); err != nil {
+		return err
+	}
+
+	//truncate synced the records before it
+	l.unsynced = 0
+	return nil
+}
+
+func (l *writeAheadLog) sync() error {
+	if l.unsynced == 0 {
+		return nil
+	}
+
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+
+	l.unsynced = 0
+	return nil
+}
+
+func (l *writeAheadLog) syncLoop(interval time.Duration) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			//there is no caller to return the error to, the next tick will retry
+			l.sync()
+			l.mu.Unlock()
+		}
+	}
+}
+
+func (l *writeAheadLog) close() error {
+	if l.stop != nil {
+		close(l.stop)
+		<-l.done
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.sync(); err != nil {
+		l.file.Close()
+		return err
+	}
+
+	return l.file.Close()
+}
@@ -0,0 +1,237 @@
+package liquiddb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func tempLogPath(t *testing.T) (string, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "liquiddb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return filepath.Join(dir, "liquid.log"), func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func openPersistent(t *testing.T, path string) *LiquidDb {
+	t.Helper()
+
+	db, err := NewWithConfig(NewConfigBuilder().LogPath(path).Finalize())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestWriteAheadLog_Replay(t *testing.T) {
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+
+	db := openPersistent(t, path)
+	db.Set(data)
+	db.SetPath([]string{"foo", "baz"}, 5)
+	db.SetPath([]string{"qux"}, map[string]interface{}{"a": 1.5})
+	db.Delete([]string{"qux", "a"})
+	db.Delete([]string{"missing"})
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db = openPersistent(t, path)
+	defer db.Close()
+
+	v, _ := db.Get([]string{"foo"})
+	if !reflect.DeepEqual(v.Value, map[string]interface{}{"bar": b, "baz": 5}) {
+		t.Fatalf("Invalid replayed value %+v", v.Value)
+	}
+
+	v, _ = db.Get([]string{"qux", "a"})
+	if v.Value != nil {
+		t.Fatalf("Deleted value replayed %+v", v.Value)
+	}
+}
+
+func TestWriteAheadLog_TornRecord(t *testing.T) {
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+
+	db := openPersistent(t, path)
+	db.Set(data)
+	db.Close()
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//a header promising more payload than was written
+	f.Write([]byte{0, 0, 0, 100, 1, 2, 3, 4, 5})
+	f.Close()
+
+	db = openPersistent(t, path)
+	v, _ := db.Get(p)
+	if !reflect.DeepEqual(v.Value, b) {
+		t.Fatalf("Invalid value after torn record %+v", v.Value)
+	}
+
+	truncated, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if truncated.Size() != stat.Size() {
+		t.Fatalf("Torn record not truncated, size %d, expected %d", truncated.Size(), stat.Size())
+	}
+
+	db.SetPath([]string{"after"}, true)
+	db.Close()
+
+	db = openPersistent(t, path)
+	defer db.Close()
+
+	v, _ = db.Get([]string{"after"})
+	if v.Value != true {
+		t.Fatalf("Record after torn record lost %+v", v.Value)
+	}
+}
+
+func TestWriteAheadLog_CorruptRecord(t *testing.T) {
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+
+	db := openPersistent(t, path)
+	db.Set(data)
+	db.SetPath([]string{"corrupt"}, "value")
+	db.Close()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content[len(content)-1] ^= 0xff
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db = openPersistent(t, path)
+	defer db.Close()
+
+	v, _ := db.Get([]string{"corrupt"})
+	if v.Value != nil {
+		t.Fatalf("Corrupt record replayed %+v", v.Value)
+	}
+
+	v, _ = db.Get(p)
+	if !reflect.DeepEqual(v.Value, b) {
+		t.Fatalf("Invalid value before corrupt record %+v", v.Value)
+	}
+}
+
+func TestWriteAheadLog_SyncPolicies(t *testing.T) {
+	for _, policy := range []SyncPolicy{SyncEveryWrite, SyncBatch, SyncInterval} {
+		t.Run(string(policy), func(t *testing.T) {
+			path, cleanup := tempLogPath(t)
+			defer cleanup()
+
+			config := NewConfigBuilder().LogPath(path).SyncPolicy(policy).SyncBatchSize(2).Finalize()
+			db, err := NewWithConfig(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			db.Set(data)
+			db.Close()
+
+			db, err = NewWithConfig(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			v, _ := db.Get(p)
+			if !reflect.DeepEqual(v.Value, b) {
+				t.Fatalf("Invalid value %+v", v.Value)
+			}
+		})
+	}
+
+	_, err := NewWithConfig(NewConfigBuilder().LogPath("unused").SyncPolicy("never").Finalize())
+	if err != ErrInvalidSyncPolicy {
+		t.Fatalf("Invalid error %v", err)
+	}
+}
+
+func TestWriteAheadLog_DeleteMissing(t *testing.T) {
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+
+	db := openPersistent(t, path)
+	defer db.Close()
+
+	db.SetPath(Path{"a"}, 1)
+	before, _ := os.Stat(path)
+
+	if _, ok := db.Delete(Path{"missing"}); ok {
+		t.Fatal("Missing path deleted")
+	}
+
+	if _, ok := db.Delete(Path{"*", "missing"}); ok {
+		t.Fatal("Missing pattern deleted")
+	}
+
+	if after, _ := os.Stat(path); after.Size() != before.Size() {
+		t.Fatalf("Delete of a missing path logged, size %d, expected %d", after.Size(), before.Size())
+	}
+}
+
+func TestWriteAheadLog_FailedApply(t *testing.T) {
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+
+	db := openPersistent(t, path)
+	db.SetPath(Path{"list"}, []interface{}{1})
+	before, _ := os.Stat(path)
+
+	//the records of a batch are only checked by the transaction staging them
+	db.writeMutex.Lock()
+	_, err := db.apply(logRecord{Operation: logOperationBatch, Records: []logRecord{
+		{Operation: logOperationSetPath, Path: Path{"list", "b"}, Value: 1},
+	}})
+	db.writeMutex.Unlock()
+	if err != ErrInvalidIndex {
+		t.Fatalf("Invalid error %v", err)
+	}
+
+	if after, _ := os.Stat(path); after.Size() != before.Size() {
+		t.Fatalf("Failed record kept in the log, size %d, expected %d", after.Size(), before.Size())
+	}
+
+	db.SetPath(Path{"a"}, 1)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = NewWithConfig(NewConfigBuilder().LogPath(path).Finalize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if v, _ := db.Get(Path{"a"}); v.Value != 1 {
+		t.Fatalf("Invalid replayed value %+v", v.Value)
+	}
+}